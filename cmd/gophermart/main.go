@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -9,13 +11,28 @@ import (
 	"time"
 
 	"github.com/ksusonic/gophermart/internal/accrual"
+	"github.com/ksusonic/gophermart/internal/audit"
 	"github.com/ksusonic/gophermart/internal/auth"
 	"github.com/ksusonic/gophermart/internal/config"
 	"github.com/ksusonic/gophermart/internal/controller"
 	"github.com/ksusonic/gophermart/internal/database"
+	"github.com/ksusonic/gophermart/internal/events"
+	"github.com/ksusonic/gophermart/internal/expiry"
+	"github.com/ksusonic/gophermart/internal/idempotency"
+	"github.com/ksusonic/gophermart/internal/lockout"
+	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/notify"
+	"github.com/ksusonic/gophermart/internal/openapi"
+	"github.com/ksusonic/gophermart/internal/ordernum"
+	"github.com/ksusonic/gophermart/internal/password"
+	"github.com/ksusonic/gophermart/internal/ratelimit"
+	"github.com/ksusonic/gophermart/internal/rpc"
 	"github.com/ksusonic/gophermart/internal/server"
+	"github.com/ksusonic/gophermart/internal/service"
+	"github.com/ksusonic/gophermart/internal/webhook"
 
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
 func main() {
@@ -27,27 +44,147 @@ func main() {
 	logger := initLogger(cfg.Debug)
 	defer logger.Sync()
 
-	db, err := database.NewDB(cfg.DatabaseURI, logger.Named("orm"))
+	isolation, err := database.ParseIsolation(cfg.DBIsolation)
+	if err != nil {
+		log.Fatalf("unable to init DB: %v", err)
+	}
+	db, err := database.NewDB(cfg.DatabaseURI, database.Config{
+		Isolation:  isolation,
+		MaxRetries: cfg.DBTxRetries,
+		Timeout:    cfg.DBTimeout,
+	}, logger.Named("orm"))
 	if err != nil {
 		log.Fatalf("unable to init DB: %v", err)
 	}
 
-	s := server.NewServer(cfg, logger)
-	s.MountController("/user", controller.NewUserController(
-		auth.NewAuthController(cfg.JwtKey),
+	authController := auth.NewAuthController(cfg.JwtKey, db)
+	auditService := audit.NewService(db, logger.Named("audit"))
+	if err = grantAdmins(cfg, db, auditService, logger.Named("admin")); err != nil {
+		log.Fatalf("unable to grant admin role: %v", err)
+	}
+	passwords, err := initPasswords(cfg)
+	if err != nil {
+		log.Fatalf("unable to init passwords: %v", err)
+	}
+	notifier, err := initNotifier(cfg, logger.Named("notify"))
+	if err != nil {
+		log.Fatalf("unable to init notifier: %v", err)
+	}
+	orderValidator, err := ordernum.NewResolver(cfg.OrderNumberSchemes)
+	if err != nil {
+		log.Fatalf("unable to init order number validation: %v", err)
+	}
+	pointsExpiry := expiry.NewService(db, cfg.PointsTTL, cfg.PointsExpiryInterval, logger.Named("expiry"))
+	loginGuard := lockout.NewGuard(db, lockout.Config{
+		MaxAttempts:   cfg.LoginMaxAttempts,
+		MaxIPAttempts: cfg.LoginMaxIPAttempts,
+		BaseLockout:   cfg.LoginLockout,
+		MaxLockout:    cfg.LoginMaxLockout,
+		Window:        cfg.LoginFailureWindow,
+	}, logger.Named("lockout"))
+
+	userService := service.NewUserService(
+		authController,
+		auditService,
+		loginGuard,
+		passwords,
+		notifier,
+		cfg.ResetTokenTTL,
 		db,
+		logger.Named("users"),
+	)
+	orderService := service.NewOrderService(orderValidator, db)
+	balanceService := service.NewBalanceService(auditService, orderValidator, pointsExpiry, db)
+	webhookDestinations := webhook.Destinations{AllowInsecure: cfg.WebhookAllowInsecure}
+	webhookService := service.NewWebhookService(auditService, webhookDestinations, db)
+
+	limiter, err := initRateLimiter(cfg, db, logger.Named("ratelimit"))
+	if err != nil {
+		log.Fatalf("unable to init rate limiter: %v", err)
+	}
+
+	spec, err := openapi.Load()
+	if err != nil {
+		log.Fatalf("unable to load OpenAPI spec: %v", err)
+	}
+
+	idempotencyMiddleware := idempotency.NewMiddleware(db, cfg.IdempotencyTTL, cfg.IdempotencyLockTimeout, logger.Named("idempotency"))
+
+	s := server.NewServer(cfg, logger)
+	if cfg.OpenAPIValidate {
+		s.Use(spec.Validator(logger.Named("openapi")))
+	}
+	s.Use(
+		controller.ErrorHandler(logger.Named("http")),
+		authController.IdentifyMiddleware(),
+		limiter.Middleware(),
+	)
+	s.MountController("", spec)
+	s.MountVersioned("/user", controller.NewUserController(
+		authController,
+		idempotencyMiddleware,
+		userService,
+		orderService,
+		balanceService,
 		logger.Named("user"),
 	))
+	s.MountController("/admin", controller.NewAdminController(
+		authController,
+		balanceService,
+		webhookService,
+		db,
+		logger.Named("admin"),
+	))
 
 	accrualWorker := accrual.NewWorker(
 		cfg.AccrualAddress,
 		db,
+		auditService,
+		cfg.AccrualRecheckWindow,
+		cfg.AccrualRecheckInterval,
 		logger.Named("accrual"),
 	)
 
+	webhookSender := webhook.NewSender(db, webhook.Config{
+		Interval:     cfg.WebhookInterval,
+		Timeout:      cfg.WebhookTimeout,
+		MaxAttempts:  cfg.WebhookMaxAttempts,
+		Backoff:      cfg.WebhookBackoff,
+		MaxBackoff:   cfg.WebhookMaxBackoff,
+		Destinations: webhookDestinations,
+	}, logger.Named("webhook"))
+
+	dispatcher := events.NewDispatcher(db, events.Config{
+		Interval:    cfg.EventsInterval,
+		MaxAttempts: cfg.EventsMaxAttempts,
+		Backoff:     cfg.EventsBackoff,
+		MaxBackoff:  cfg.EventsMaxBackoff,
+	}, logger.Named("events"))
+	dispatcher.Subscribe("log", events.LogHandler(logger.Named("events")))
+	dispatcher.Subscribe("webhooks", webhook.NewPublisher(db).Handle)
+
 	ctx, cancel := context.WithCancel(context.Background())
 	srv := s.Run(cfg.Address)
+	var grpcSrv *grpc.Server
+	if cfg.GRPCAddress != "" {
+		grpcSrv, err = rpc.NewServer(
+			authController,
+			userService,
+			orderService,
+			balanceService,
+			limiter,
+			logger.Named("grpc"),
+		).Run(cfg.GRPCAddress)
+		if err != nil {
+			logger.Fatalf("unable to start gRPC server: %v", err)
+		}
+	}
 	go accrualWorker.Run(ctx)
+	go pointsExpiry.Run(ctx)
+	go webhookSender.Run(ctx)
+	go dispatcher.Run(ctx)
+	go limiter.Run(ctx)
+	go idempotencyMiddleware.Run(ctx)
 
 	defer cancel()
 
@@ -62,10 +199,97 @@ func main() {
 	if srvErr := srv.Shutdown(toCtx); srvErr != nil {
 		logger.Fatalf("s shutdown error: %v", srvErr)
 	}
+	if grpcSrv != nil {
+		grpcSrv.GracefulStop()
+	}
 
 	logger.Info("server stopped")
 }
 
+// grantAdmins bootstraps admins from config, logins not registered yet are reported and skipped
+func grantAdmins(cfg *config.Config, db *database.DB, auditService *audit.Service, logger *zap.SugaredLogger) error {
+	if len(cfg.AdminLogins) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	granted, err := db.GrantAdmin(ctx, cfg.AdminLogins)
+	if err != nil {
+		return err
+	}
+	for _, user := range *granted {
+		auditService.Record(ctx, &models.AuditEvent{
+			Action: models.AuditActionAdminGranted,
+			Target: user.Login,
+		})
+		logger.Infof("granted admin role to %s", user.Login)
+	}
+	for _, login := range cfg.AdminLogins {
+		if _, err := db.GetUserByLogin(ctx, login); errors.Is(err, models.ErrNotFound) {
+			logger.Warnf("admin %s is not registered, restart after registration to grant the role", login)
+		}
+	}
+	return nil
+}
+
+func initPasswords(cfg *config.Config) (*password.Manager, error) {
+	hasher, err := password.NewHasher(cfg.PasswordHash, cfg.BcryptCost, password.Argon2Params{
+		Memory:  cfg.Argon2Memory,
+		Time:    cfg.Argon2Time,
+		Threads: cfg.Argon2Threads,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	maxLength := hasher.MaxLength()
+	if cfg.PasswordMaxLength > maxLength {
+		return nil, fmt.Errorf("%s hashes passwords up to %d bytes, got max length %d", cfg.PasswordHash, maxLength, cfg.PasswordMaxLength)
+	}
+	if cfg.PasswordMaxLength > 0 {
+		maxLength = cfg.PasswordMaxLength
+	}
+
+	return password.NewManager(password.Policy{
+		MinLength:  cfg.PasswordMinLength,
+		MaxLength:  maxLength,
+		MinClasses: cfg.PasswordMinClasses,
+	}, hasher), nil
+}
+
+func initNotifier(cfg *config.Config, logger *zap.SugaredLogger) (service.Notifier, error) {
+	switch cfg.ResetNotifier {
+	case "log":
+		return notify.NewLogNotifier(logger), nil
+	case "file":
+		return notify.NewFileNotifier(cfg.ResetNotifierFile), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier: %s", cfg.ResetNotifier)
+	}
+}
+
+func initRateLimiter(cfg *config.Config, db *database.DB, logger *zap.SugaredLogger) (*ratelimit.Limiter, error) {
+	defaultLimit, err := ratelimit.ParseLimit(cfg.RateLimitDefault)
+	if err != nil {
+		return nil, err
+	}
+	routes, err := ratelimit.ParseRoutes(cfg.RateLimitRoutes)
+	if err != nil {
+		return nil, err
+	}
+
+	var store ratelimit.Store
+	switch cfg.RateLimitStore {
+	case "memory":
+		store = ratelimit.NewMemoryStore()
+	case "postgres":
+		store = ratelimit.NewPostgresStore(db)
+	default:
+		return nil, fmt.Errorf("unknown rate limit store: %s", cfg.RateLimitStore)
+	}
+
+	return ratelimit.NewLimiter(store, defaultLimit, routes, logger), nil
+}
+
 func initLogger(debug bool) *zap.SugaredLogger {
 	if debug {
 		logger, _ := zap.NewDevelopment()
@@ -0,0 +1,66 @@
+// Command webhookreceiver is a local endpoint for testing webhook subscriptions:
+// it verifies signatures and prints received events. The server sends webhooks
+// to it over plain http only with WEBHOOK_ALLOW_INSECURE set
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/webhook"
+)
+
+// tolerance is how old deliveries may be, older ones are taken for replays
+const tolerance = 5 * time.Minute
+
+func main() {
+	address := flag.String("a", ":9090", "listen address")
+	secret := flag.String("secret", os.Getenv("WEBHOOK_SECRET"), "subscription secret, signatures are not checked if empty")
+	status := flag.Int("status", http.StatusOK, "status to respond with, to test retries")
+	flag.Parse()
+
+	http.Handle("/", handler(*secret, *status))
+
+	log.Printf("listening on %s", *address)
+	log.Fatal(http.ListenAndServe(*address, nil))
+}
+
+// handler verifies deliveries signed with secret, if it is set, and responds with status
+func handler(secret string, status int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		verified := "unverified"
+		if secret != "" {
+			if !webhook.Verify(
+				secret,
+				r.Header.Get(webhook.HeaderTimestamp),
+				r.Header.Get(webhook.HeaderSignature),
+				body,
+				tolerance,
+			) {
+				log.Printf("delivery %s: invalid signature", r.Header.Get(webhook.HeaderDelivery))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			verified = "verified"
+		}
+
+		log.Printf(
+			"delivery %s %s (%s): %s",
+			r.Header.Get(webhook.HeaderDelivery),
+			r.Header.Get(webhook.HeaderEvent),
+			verified,
+			body,
+		)
+		w.WriteHeader(status)
+	}
+}
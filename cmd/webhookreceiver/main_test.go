@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/webhook"
+)
+
+func TestHandlerVerifiesSignedDeliveries(t *testing.T) {
+	body := []byte(`{"order":"79927398713"}`)
+	tests := []struct {
+		name       string
+		secret     string
+		signedWith string
+		sentAt     time.Time
+		body       []byte
+		wantStatus int
+	}{
+		{name: "signed", secret: "secret", signedWith: "secret", sentAt: time.Now(), body: body, wantStatus: http.StatusAccepted},
+		{name: "wrong secret", secret: "secret", signedWith: "other", sentAt: time.Now(), body: body, wantStatus: http.StatusUnauthorized},
+		{name: "tampered body", secret: "secret", signedWith: "secret", sentAt: time.Now(), body: []byte(`{"order":"1"}`), wantStatus: http.StatusUnauthorized},
+		{name: "replayed", secret: "secret", signedWith: "secret", sentAt: time.Now().Add(-tolerance - time.Minute), body: body, wantStatus: http.StatusUnauthorized},
+		{name: "not checked without secret", signedWith: "other", sentAt: time.Now(), body: body, wantStatus: http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+			request.Header.Set(webhook.HeaderDelivery, "1")
+			request.Header.Set(webhook.HeaderTimestamp, strconv.FormatInt(tt.sentAt.Unix(), 10))
+			request.Header.Set(webhook.HeaderSignature, webhook.Sign(tt.signedWith, tt.sentAt, body))
+			recorder := httptest.NewRecorder()
+
+			handler(tt.secret, http.StatusAccepted).ServeHTTP(recorder, request)
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", recorder.Code, tt.wantStatus)
+			}
+		})
+	}
+}
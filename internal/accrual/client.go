@@ -2,8 +2,6 @@ package accrual
 
 import (
 	"context"
-	"database/sql"
-	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -15,55 +13,84 @@ import (
 type Worker struct {
 	accrualAddress string
 	db             DB
+	audit          Auditor
 	logger         *zap.SugaredLogger
 
-	updateRate time.Duration
-	client     *http.Client
+	updateRate    time.Duration
+	recheckWindow time.Duration
+	recheckRate   time.Duration
+	client        *http.Client
 }
 
-func NewWorker(accrualAddress string, db DB, logger *zap.SugaredLogger) *Worker {
+func NewWorker(
+	accrualAddress string,
+	db DB,
+	auditor Auditor,
+	recheckWindow time.Duration,
+	recheckRate time.Duration,
+	logger *zap.SugaredLogger,
+) *Worker {
 	return &Worker{
 		accrualAddress: accrualAddress,
 		db:             db,
+		audit:          auditor,
 		logger:         logger,
 
-		updateRate: time.Second * 3,
-		client:     &http.Client{}, // for client customization
+		updateRate:    time.Second * 3,
+		recheckWindow: recheckWindow,
+		recheckRate:   recheckRate,
+		client:        &http.Client{}, // for client customization
 	}
 }
 
 func (w *Worker) Run(ctx context.Context) {
 	w.logger.Infof("Started accrual worker")
 	ticker := time.NewTicker(w.updateRate)
-	select {
-	case <-ticker.C:
-		if err := w.processAccrual(); err != nil {
-			w.logger.Errorf("error processing accrual: %v", err)
+	defer ticker.Stop()
+
+	// nil channel never fires, so re-verification stays off
+	var recheck <-chan time.Time
+	if w.recheckWindow > 0 {
+		recheckTicker := time.NewTicker(w.recheckRate)
+		defer recheckTicker.Stop()
+		recheck = recheckTicker.C
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.processAccrual(ctx); err != nil {
+				w.logger.Errorf("error processing accrual: %v", err)
+			}
+		case <-recheck:
+			if err := w.recheckAccrual(ctx); err != nil {
+				w.logger.Errorf("error re-verifying accrual: %v", err)
+			}
+		case <-ctx.Done():
+			return
 		}
-	case <-ctx.Done():
-		return
 	}
 }
 
-func (w *Worker) processAccrual() error {
-	orders, err := w.getOrdersToCheck()
-	if errors.Is(err, sql.ErrNoRows) {
-		w.logger.Debug("No orders for accrual count")
-		return nil
-	}
+func (w *Worker) processAccrual(ctx context.Context) error {
+	orders, err := w.getOrdersToCheck(ctx)
 	if err != nil {
 		return fmt.Errorf("could not get orders from db: %w", err)
 	}
+	if len(orders) == 0 {
+		w.logger.Debug("No orders for accrual count")
+		return nil
+	}
 
 	eg := &errgroup.Group{}
 	for i := range orders {
-		response, err := w.getOrderInfo(orders[i].ID)
+		response, err := w.getOrderInfo(ctx, orders[i].ID)
 		if err != nil {
 			return fmt.Errorf("could not request order info: %v", err)
 		}
 		order := orders[i]
 		eg.Go(func() error {
-			return w.processOrder(response, &order)
+			return w.processOrder(ctx, response, &order)
 		})
 	}
 	if err := eg.Wait(); err != nil {
@@ -1,18 +1,26 @@
 package accrual
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/ksusonic/gophermart/internal/models"
 )
 
 type DB interface {
-	GetOrdersWithStatus(status ...models.OrderStatus) (*[]models.Order, error)
-	UpdateOrder(order *models.Order) error
+	GetOrdersWithStatus(ctx context.Context, status ...models.OrderStatus) (*[]models.Order, error)
+	UpdateOrder(ctx context.Context, order *models.Order) error
+	GetOrdersAccruedSince(ctx context.Context, since time.Time) (*[]models.Order, error)
+	AdjustAccrual(ctx context.Context, order *models.Order, accrual int64, status models.OrderStatus) (*models.AccrualAdjustment, error)
 }
 
-func (w *Worker) getOrdersToCheck() ([]models.Order, error) {
-	orders, err := w.db.GetOrdersWithStatus(models.OrderStatusNew, models.OrderStatusProcessing)
+type Auditor interface {
+	Record(ctx context.Context, event *models.AuditEvent)
+}
+
+func (w *Worker) getOrdersToCheck(ctx context.Context) ([]models.Order, error) {
+	orders, err := w.db.GetOrdersWithStatus(ctx, models.OrderStatusNew, models.OrderStatusProcessing)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get orders: %w", err)
 	}
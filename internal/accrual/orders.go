@@ -1,20 +1,31 @@
 package accrual
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"time"
 
 	"github.com/ksusonic/gophermart/internal/api"
+	"github.com/ksusonic/gophermart/internal/audit"
 	"github.com/ksusonic/gophermart/internal/models"
 )
 
 const OrdersHandler = "/api/orders/"
 
-func (w *Worker) getOrderInfo(number string) (*api.AccrualResponse, error) {
-	response, err := w.client.Get(w.accrualAddress + OrdersHandler + number)
+var errRateLimited = errors.New("ratelimited")
+
+func (w *Worker) getOrderInfo(ctx context.Context, number string) (*api.AccrualResponse, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, w.accrualAddress+OrdersHandler+number, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := w.client.Do(request)
 	if err != nil {
 		return nil, err
 	}
@@ -24,7 +35,7 @@ func (w *Worker) getOrderInfo(number string) (*api.AccrualResponse, error) {
 	case http.StatusNoContent:
 		return nil, fmt.Errorf("order %s not registered in accrual system", number)
 	case http.StatusTooManyRequests:
-		return nil, fmt.Errorf("ratelimited")
+		return nil, errRateLimited
 	case http.StatusOK:
 		bytes, err := io.ReadAll(response.Body)
 		if err != nil {
@@ -41,22 +52,26 @@ func (w *Worker) getOrderInfo(number string) (*api.AccrualResponse, error) {
 	}
 }
 
-func (w *Worker) processOrder(response *api.AccrualResponse, order *models.Order) error {
+func (w *Worker) processOrder(ctx context.Context, response *api.AccrualResponse, order *models.Order) error {
+	before := orderState(order)
 	switch response.Status {
 	case api.AccrualStatusProcessed:
 		order.Status = models.OrderStatusProcessed
 		order.Accrual = sql.NullInt64{
-			Int64: int64(response.Accrual * 100),
+			Int64: accrualAmount(response.Accrual),
 			Valid: true,
 		}
-		err := w.db.UpdateOrder(order)
+		order.AccruedAt = sql.NullTime{
+			Time:  time.Now(),
+			Valid: true,
+		}
+		err := w.db.UpdateOrder(ctx, order)
 		if err != nil {
 			return fmt.Errorf("error updating order: %v", err)
 		}
-		w.logger.Infof("order %s is processed!", order.ID)
 	case api.AccrualStatusProcessing:
-		order.Status = models.OrderStatusInvalid
-		err := w.db.UpdateOrder(order)
+		order.Status = models.OrderStatusProcessing
+		err := w.db.UpdateOrder(ctx, order)
 		if err != nil {
 			return fmt.Errorf("error updating order: %v", err)
 		}
@@ -65,12 +80,39 @@ func (w *Worker) processOrder(response *api.AccrualResponse, order *models.Order
 		w.logger.Debugf("order %s is registered", order.ID)
 	case api.AccrualStatusInvalid:
 		order.Status = models.OrderStatusInvalid
-		err := w.db.UpdateOrder(order)
+		err := w.db.UpdateOrder(ctx, order)
 		if err != nil {
 			return fmt.Errorf("error updating order: %v", err)
 		}
 	default:
-		w.logger.Warn("unknown status from accrual: %s", response.Status)
+		w.logger.Warnf("unknown status from accrual: %s", response.Status)
+	}
+
+	if after := orderState(order); after != before {
+		w.audit.Record(ctx, &models.AuditEvent{
+			ActorID: audit.Actor(order.UserID),
+			Action:  models.AuditActionOrderStatus,
+			Target:  order.ID,
+			Before:  audit.Value(before),
+			After:   audit.Value(after),
+		})
 	}
 	return nil
 }
+
+type auditOrderState struct {
+	Status  models.OrderStatus `json:"status"`
+	Accrual int64              `json:"accrual"`
+}
+
+func orderState(order *models.Order) auditOrderState {
+	return auditOrderState{
+		Status:  order.Status,
+		Accrual: order.Accrual.Int64,
+	}
+}
+
+// accrualAmount converts points to hundredths of a point
+func accrualAmount(accrual float64) int64 {
+	return int64(math.Round(accrual * 100))
+}
@@ -0,0 +1,113 @@
+package accrual
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/api"
+	"github.com/ksusonic/gophermart/internal/models"
+
+	"go.uber.org/zap"
+)
+
+type fakeDB struct {
+	updated []models.Order
+}
+
+func (db *fakeDB) GetOrdersWithStatus(context.Context, ...models.OrderStatus) (*[]models.Order, error) {
+	return &[]models.Order{}, nil
+}
+
+func (db *fakeDB) UpdateOrder(_ context.Context, order *models.Order) error {
+	db.updated = append(db.updated, *order)
+	return nil
+}
+
+func (db *fakeDB) GetOrdersAccruedSince(context.Context, time.Time) (*[]models.Order, error) {
+	return &[]models.Order{}, nil
+}
+
+func (db *fakeDB) AdjustAccrual(_ context.Context, order *models.Order, accrual int64, status models.OrderStatus) (*models.AccrualAdjustment, error) {
+	adjustment := &models.AccrualAdjustment{
+		OrderID: order.ID,
+		UserID:  order.UserID,
+		Before:  order.Accrual.Int64,
+		After:   accrual,
+		Delta:   accrual - order.Accrual.Int64,
+	}
+	order.Accrual = sql.NullInt64{Int64: accrual, Valid: true}
+	order.Status = status
+	return adjustment, nil
+}
+
+type fakeAuditor struct {
+	events []models.AuditEvent
+}
+
+func (a *fakeAuditor) Record(_ context.Context, event *models.AuditEvent) {
+	a.events = append(a.events, *event)
+}
+
+func newTestWorker(db DB) (*Worker, *fakeAuditor) {
+	auditor := &fakeAuditor{}
+	return NewWorker("", db, auditor, 0, time.Minute, zap.NewNop().Sugar()), auditor
+}
+
+func TestProcessOrder(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    api.AccrualResponse
+		wantStatus  models.OrderStatus
+		wantAccrual int64
+		wantUpdate  bool
+	}{
+		{
+			name:        "processed",
+			response:    api.AccrualResponse{Status: api.AccrualStatusProcessed, Accrual: 729.98},
+			wantStatus:  models.OrderStatusProcessed,
+			wantAccrual: 72998,
+			wantUpdate:  true,
+		},
+		{
+			name:       "processing is not final",
+			response:   api.AccrualResponse{Status: api.AccrualStatusProcessing},
+			wantStatus: models.OrderStatusProcessing,
+			wantUpdate: true,
+		},
+		{
+			name:       "invalid",
+			response:   api.AccrualResponse{Status: api.AccrualStatusInvalid},
+			wantStatus: models.OrderStatusInvalid,
+			wantUpdate: true,
+		},
+		{
+			name:       "registered",
+			response:   api.AccrualResponse{Status: api.AccrualStatusRegistered},
+			wantStatus: models.OrderStatusNew,
+		},
+		{
+			name:       "unknown",
+			response:   api.AccrualResponse{Status: "LOST"},
+			wantStatus: models.OrderStatusNew,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{}
+			worker, _ := newTestWorker(db)
+			order := &models.Order{ID: "79927398713", UserID: 1, Status: models.OrderStatusNew}
+
+			if err := worker.processOrder(context.Background(), &tt.response, order); err != nil {
+				t.Fatal(err)
+			}
+			if order.Status != tt.wantStatus || order.Accrual.Int64 != tt.wantAccrual {
+				t.Errorf("got order %s with %d, want %s with %d", order.Status, order.Accrual.Int64, tt.wantStatus, tt.wantAccrual)
+			}
+			if updated := len(db.updated) > 0; updated != tt.wantUpdate {
+				t.Errorf("order updated %v, want %v", updated, tt.wantUpdate)
+			}
+		})
+	}
+}
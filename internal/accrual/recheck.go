@@ -0,0 +1,91 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/api"
+	"github.com/ksusonic/gophermart/internal/audit"
+	"github.com/ksusonic/gophermart/internal/models"
+)
+
+// recheckAccrual asks accrual system again about orders processed within recheck window
+// and posts adjustments for the ones whose accrual was corrected or revoked
+func (w *Worker) recheckAccrual(ctx context.Context) error {
+	orders, err := w.db.GetOrdersAccruedSince(ctx, time.Now().Add(-w.recheckWindow))
+	if err != nil {
+		return fmt.Errorf("could not get orders from db: %w", err)
+	}
+
+	for i := range *orders {
+		if ctx.Err() != nil {
+			return nil // shutting down
+		}
+		order := &(*orders)[i]
+		response, err := w.getOrderInfo(ctx, order.ID)
+		if errors.Is(err, errRateLimited) {
+			return err
+		}
+		if err != nil {
+			w.logger.Warnf("could not re-verify order %s: %v", order.ID, err)
+			continue
+		}
+		if err := w.recheckOrder(ctx, response, order); err != nil {
+			w.logger.Errorf("could not adjust order %s: %v", order.ID, err)
+		}
+	}
+	return nil
+}
+
+func (w *Worker) recheckOrder(ctx context.Context, response *api.AccrualResponse, order *models.Order) error {
+	var (
+		accrual int64
+		status  models.OrderStatus
+	)
+	switch response.Status {
+	case api.AccrualStatusProcessed:
+		accrual, status = accrualAmount(response.Accrual), models.OrderStatusProcessed
+	case api.AccrualStatusInvalid:
+		accrual, status = 0, models.OrderStatusInvalid
+	default:
+		// order went back to processing, wait for final state
+		w.logger.Debugf("order %s is %s on re-verification", order.ID, response.Status)
+		return nil
+	}
+	if accrual == order.Accrual.Int64 && status == order.Status {
+		return nil
+	}
+
+	before := orderState(order)
+	adjustment, err := w.db.AdjustAccrual(ctx, order, accrual, status)
+	if errors.Is(err, models.ErrConflict) {
+		w.logger.Debugf("order %s changed concurrently, skipping", order.ID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if adjustment.Flagged {
+		w.logger.Warnf(
+			"balance of user %d is negative after adjustment of order %s: %d",
+			adjustment.UserID, order.ID, adjustment.BalanceAfter,
+		)
+	}
+	w.audit.Record(ctx, &models.AuditEvent{
+		ActorID: audit.Actor(order.UserID),
+		Action:  models.AuditActionOrderAdjusted,
+		Target:  order.ID,
+		Before:  audit.Value(before),
+		After:   audit.Value(adjustmentState{orderState(order), adjustment.BalanceAfter, adjustment.Flagged}),
+	})
+	return nil
+}
+
+type adjustmentState struct {
+	auditOrderState
+	BalanceAfter int64 `json:"balance_after"`
+	Flagged      bool  `json:"flagged"`
+}
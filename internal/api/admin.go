@@ -0,0 +1,44 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditQuery struct {
+	ActorID *uint     `form:"actor_id"`
+	Action  string    `form:"action"`
+	Target  string    `form:"target"`
+	From    time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To      time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit   int       `form:"limit" binding:"omitempty,min=1,max=1000"`
+	Offset  int       `form:"offset" binding:"omitempty,min=0"`
+}
+
+type AuditEvent struct {
+	ID        uint            `json:"id"`
+	CreatedAt string          `json:"created_at"`
+	ActorID   *int64          `json:"actor_id"`
+	Action    string          `json:"action"`
+	Target    string          `json:"target,omitempty"`
+	IP        string          `json:"ip,omitempty"`
+	UserAgent string          `json:"user_agent,omitempty"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+}
+
+type AdjustmentQuery struct {
+	Flagged bool `form:"flagged"`
+}
+
+type AccrualAdjustment struct {
+	ID           uint    `json:"id"`
+	CreatedAt    string  `json:"created_at"`
+	Order        string  `json:"order"`
+	UserID       uint    `json:"user_id"`
+	Before       float64 `json:"before"`
+	After        float64 `json:"after"`
+	Delta        float64 `json:"delta"`
+	BalanceAfter float64 `json:"balance_after"`
+	Flagged      bool    `json:"flagged"`
+}
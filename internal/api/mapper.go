@@ -0,0 +1,165 @@
+package api
+
+import (
+	"math"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/models"
+)
+
+// WithdrawalInput is withdraw request of any version, Sum is in hundredths of a point
+type WithdrawalInput struct {
+	Order string
+	Sum   int64
+	Hold  bool
+}
+
+// WithdrawalRequest is body of withdraw request
+type WithdrawalRequest interface {
+	Input() WithdrawalInput
+}
+
+func (r *WithdrawRequest) Input() WithdrawalInput {
+	return WithdrawalInput{Order: r.Order, Sum: int64(math.Round(r.Sum * 100)), Hold: r.Hold}
+}
+
+func (r *WithdrawRequestV2) Input() WithdrawalInput {
+	return WithdrawalInput{Order: r.Order, Sum: r.Sum, Hold: r.Hold}
+}
+
+// Mapper converts models to DTOs of one API version
+type Mapper interface {
+	// NoContent tells whether empty lists are answered with 204
+	NoContent() bool
+	// Page is fetched for list query
+	Page(query PageQuery) models.Page
+	WithdrawalRequest() WithdrawalRequest
+
+	Orders(orders []models.Order, page models.Page, hasMore bool) any
+	Balance(balance *models.Balance) any
+	ExpiringPoints(points []models.ExpiringPoints) any
+	Withdrawals(withdrawals []models.Withdrawal, page models.Page, hasMore bool) any
+}
+
+func MapperFor(version Version) Mapper {
+	if version == V2 {
+		return mapperV2{}
+	}
+	return mapperV1{}
+}
+
+type mapperV1 struct{}
+
+func (mapperV1) NoContent() bool { return true }
+
+// Page of v1 is the whole list
+func (mapperV1) Page(PageQuery) models.Page { return models.Page{} }
+
+func (mapperV1) WithdrawalRequest() WithdrawalRequest { return &WithdrawRequest{} }
+
+func (mapperV1) Orders(orders []models.Order, _ models.Page, _ bool) any {
+	response := make([]Order, len(orders))
+	for i := range orders {
+		response[i] = Order{
+			Number:     orders[i].ID,
+			Status:     orders[i].Status,
+			UploadedAt: orders[i].CreatedAt.Format(time.RFC3339),
+		}
+		if orders[i].Accrual.Valid {
+			response[i].Accrual = float64(orders[i].Accrual.Int64) / 100
+		}
+	}
+	return response
+}
+
+func (mapperV1) Balance(balance *models.Balance) any {
+	return BalanceResponse{
+		Current:   float64(balance.Current) / 100,
+		Withdrawn: float64(balance.Withdrawn) / 100,
+		Pending:   float64(balance.Pending) / 100,
+	}
+}
+
+func (mapperV1) ExpiringPoints(points []models.ExpiringPoints) any {
+	response := make([]ExpiringPoints, len(points))
+	for i := range points {
+		response[i] = ExpiringPoints{
+			Amount:    float64(points[i].Amount) / 100,
+			ExpiresAt: points[i].ExpiresAt.Format(time.RFC3339),
+		}
+	}
+	return response
+}
+
+func (mapperV1) Withdrawals(withdrawals []models.Withdrawal, _ models.Page, _ bool) any {
+	response := make(WithdrawResponse, len(withdrawals))
+	for i := range withdrawals {
+		response[i] = Withdraw{
+			Order:       withdrawals[i].OrderID,
+			Sum:         float64(withdrawals[i].Sum) / 100,
+			Status:      withdrawals[i].Status,
+			ProcessedAt: withdrawals[i].CreatedAt.Format(time.RFC3339),
+		}
+	}
+	return response
+}
+
+type mapperV2 struct{}
+
+func (mapperV2) NoContent() bool { return false }
+
+func (mapperV2) Page(query PageQuery) models.Page { return query.Page() }
+
+func (mapperV2) WithdrawalRequest() WithdrawalRequest { return &WithdrawRequestV2{} }
+
+func (mapperV2) Orders(orders []models.Order, page models.Page, hasMore bool) any {
+	response := OrdersV2{PageV2: pageV2(page, hasMore), Items: make([]OrderV2, len(orders))}
+	for i := range orders {
+		response.Items[i] = OrderV2{
+			Number:     orders[i].ID,
+			Status:     orders[i].Status,
+			UploadedAt: orders[i].CreatedAt.Format(time.RFC3339),
+		}
+		if orders[i].Accrual.Valid {
+			accrual := orders[i].Accrual.Int64
+			response.Items[i].Accrual = &accrual
+		}
+	}
+	return response
+}
+
+func (mapperV2) Balance(balance *models.Balance) any {
+	return BalanceV2{
+		Current:   balance.Current,
+		Withdrawn: balance.Withdrawn,
+		Pending:   balance.Pending,
+	}
+}
+
+func (mapperV2) ExpiringPoints(points []models.ExpiringPoints) any {
+	response := make([]ExpiringPointsV2, len(points))
+	for i := range points {
+		response[i] = ExpiringPointsV2{
+			Amount:    points[i].Amount,
+			ExpiresAt: points[i].ExpiresAt.Format(time.RFC3339),
+		}
+	}
+	return response
+}
+
+func (mapperV2) Withdrawals(withdrawals []models.Withdrawal, page models.Page, hasMore bool) any {
+	response := WithdrawalsV2{PageV2: pageV2(page, hasMore), Items: make([]WithdrawV2, len(withdrawals))}
+	for i := range withdrawals {
+		response.Items[i] = WithdrawV2{
+			Order:       withdrawals[i].OrderID,
+			Sum:         withdrawals[i].Sum,
+			Status:      withdrawals[i].Status,
+			ProcessedAt: withdrawals[i].CreatedAt.Format(time.RFC3339),
+		}
+	}
+	return response
+}
+
+func pageV2(page models.Page, hasMore bool) PageV2 {
+	return PageV2{Limit: page.Limit, Offset: page.Offset, HasMore: hasMore}
+}
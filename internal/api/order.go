@@ -2,6 +2,7 @@ package api
 
 import (
 	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/service"
 )
 
 type Order struct {
@@ -11,14 +12,29 @@ type Order struct {
 	UploadedAt string             `json:"uploaded_at"`
 }
 
+type BatchOrder struct {
+	Number string               `json:"number"`
+	Result service.UploadResult `json:"result"`
+}
+
+func BatchOrders(results []service.BatchResult) []BatchOrder {
+	response := make([]BatchOrder, len(results))
+	for i, result := range results {
+		response[i] = BatchOrder{Number: result.Number, Result: result.Result}
+	}
+	return response
+}
+
 type WithdrawRequest struct {
 	Order string  `json:"order"`
 	Sum   float64 `json:"sum"`
+	Hold  bool    `json:"hold"` // keep pending until merchant confirms
 }
 
 type WithdrawResponse []Withdraw
 type Withdraw struct {
-	Order       string  `json:"order"`
-	Sum         float64 `json:"sum"`
-	ProcessedAt string  `json:"processed_at"`
+	Order       string                  `json:"order"`
+	Sum         float64                 `json:"sum"`
+	Status      models.WithdrawalStatus `json:"status"`
+	ProcessedAt string                  `json:"processed_at"`
 }
@@ -0,0 +1,18 @@
+package api
+
+const ProblemContentType = "application/problem+json"
+
+// Problem is RFC 7807 error response. Code is stable, Detail is localized by Accept-Language
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+type StatusResponse struct {
+	Status string `json:"status"`
+}
@@ -5,12 +5,27 @@ type User struct {
 	Password string `json:"password"`
 }
 
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type PasswordResetRequest struct {
+	Login string `json:"login" binding:"required"`
+}
+
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password"`
+}
+
 type BalanceResponse struct {
 	Current   float64 `json:"current"`
 	Withdrawn float64 `json:"withdrawn"`
+	Pending   float64 `json:"pending"`
 }
 
-type UserInfo struct {
-	Balance  int64
-	Withdraw int64
+type ExpiringPoints struct {
+	Amount    float64 `json:"amount"`
+	ExpiresAt string  `json:"expires_at"`
 }
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"github.com/ksusonic/gophermart/internal/models"
+)
+
+// DTOs of v2, amounts are integer hundredths of a point
+
+// PageQuery is checked by services, so bounds are the same for every transport
+type PageQuery struct {
+	Limit  int `form:"limit"`
+	Offset int `form:"offset"`
+}
+
+// Page returns queried page, of default size if limit is not given
+func (q PageQuery) Page() models.Page {
+	limit := q.Limit
+	if limit == 0 {
+		limit = models.DefaultPageLimit
+	}
+	return models.Page{Limit: limit, Offset: q.Offset}
+}
+
+type PageV2 struct {
+	Limit   int  `json:"limit"`
+	Offset  int  `json:"offset"`
+	HasMore bool `json:"has_more"`
+}
+
+type OrderV2 struct {
+	Number     string             `json:"number"`
+	Status     models.OrderStatus `json:"status"`
+	Accrual    *int64             `json:"accrual,omitempty"`
+	UploadedAt string             `json:"uploaded_at"`
+}
+
+type OrdersV2 struct {
+	PageV2
+	Items []OrderV2 `json:"items"`
+}
+
+type BalanceV2 struct {
+	Current   int64 `json:"current"`
+	Withdrawn int64 `json:"withdrawn"`
+	Pending   int64 `json:"pending"`
+}
+
+type ExpiringPointsV2 struct {
+	Amount    int64  `json:"amount"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+type WithdrawRequestV2 struct {
+	Order string `json:"order"`
+	Sum   int64  `json:"sum"`
+	Hold  bool   `json:"hold"`
+}
+
+type WithdrawV2 struct {
+	Order       string                  `json:"order"`
+	Sum         int64                   `json:"sum"`
+	Status      models.WithdrawalStatus `json:"status"`
+	ProcessedAt string                  `json:"processed_at"`
+}
+
+type WithdrawalsV2 struct {
+	PageV2
+	Items []WithdrawV2 `json:"items"`
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"mime"
+	"strings"
+
+	"github.com/ksusonic/gophermart/internal/ctxdata"
+)
+
+type Version int
+
+const (
+	V1 Version = 1
+	V2 Version = 2 // money in hundredths of a point, paginated lists
+)
+
+const versionKey = "api_version"
+
+// V2Prefix is the path prefix of v2 routes
+const V2Prefix = "/api/v2"
+
+// V2MediaType in Accept selects v2 on unversioned paths
+const V2MediaType = "application/vnd.gophermart.v2+json"
+
+// NegotiateVersion returns V2 if accept asks for V2MediaType, fallback otherwise
+func NegotiateVersion(accept string, fallback Version) Version {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && mediaType == V2MediaType {
+			return V2
+		}
+	}
+	return fallback
+}
+
+// Unversioned returns path of v1 route that shares handler with route of path
+func Unversioned(path string) string {
+	if strings.HasPrefix(path, V2Prefix+"/") {
+		return "/api" + strings.TrimPrefix(path, V2Prefix)
+	}
+	return path
+}
+
+// VersionOf returns version of route, V1 if it is not versioned
+func VersionOf(ctx ctxdata.Keys) Version {
+	if version, ok := ctx.Get(versionKey); ok {
+		return version.(Version)
+	}
+	return V1
+}
+
+func SetVersion(ctx ctxdata.Keys, version Version) {
+	ctx.Set(versionKey, version)
+}
@@ -0,0 +1,65 @@
+package api
+
+// WebhookPayload is signed and posted to partner endpoints
+type WebhookPayload struct {
+	Event      string `json:"event"`
+	OccurredAt string `json:"occurred_at"`
+	Data       any    `json:"data"`
+}
+
+type WebhookOrder struct {
+	Number  string  `json:"number"`
+	UserID  uint    `json:"user_id"`
+	Status  string  `json:"status"`
+	Accrual float64 `json:"accrual,omitempty"`
+}
+
+type WebhookAdjustment struct {
+	Number string  `json:"number"`
+	UserID uint    `json:"user_id"`
+	Before float64 `json:"before"`
+	After  float64 `json:"after"`
+	Delta  float64 `json:"delta"`
+}
+
+type WebhookWithdrawal struct {
+	Order  string  `json:"order"`
+	UserID uint    `json:"user_id"`
+	Sum    float64 `json:"sum"`
+	Status string  `json:"status"`
+}
+
+type WebhookSubscriptionRequest struct {
+	Partner string   `json:"partner" binding:"required"`
+	URL     string   `json:"url" binding:"required,url"`
+	Events  []string `json:"events"`
+	Secret  string   `json:"secret"` // generated if empty
+}
+
+type WebhookSubscription struct {
+	ID        uint     `json:"id"`
+	CreatedAt string   `json:"created_at"`
+	Partner   string   `json:"partner"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Secret    string   `json:"secret,omitempty"` // only shown on creation
+}
+
+type WebhookDelivery struct {
+	ID            uint             `json:"id"`
+	CreatedAt     string           `json:"created_at"`
+	Event         string           `json:"event"`
+	Attempts      int              `json:"attempts"`
+	NextAttemptAt string           `json:"next_attempt_at,omitempty"`
+	DeliveredAt   string           `json:"delivered_at,omitempty"`
+	FailedAt      string           `json:"failed_at,omitempty"`
+	LastError     string           `json:"last_error,omitempty"`
+	Log           []WebhookAttempt `json:"log"`
+}
+
+type WebhookAttempt struct {
+	CreatedAt  string `json:"created_at"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
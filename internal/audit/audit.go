@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/ksusonic/gophermart/internal/models"
+
+	"go.uber.org/zap"
+)
+
+type DB interface {
+	CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error
+}
+
+type Service struct {
+	db     DB
+	logger *zap.SugaredLogger
+}
+
+func NewService(db DB, logger *zap.SugaredLogger) *Service {
+	return &Service{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Record stores event. Failures are logged and never break the caller
+func (s *Service) Record(ctx context.Context, event *models.AuditEvent) {
+	if err := s.db.CreateAuditEvent(ctx, event); err != nil {
+		s.logger.Errorf("could not record audit event %s on %s: %v", event.Action, event.Target, err)
+	}
+}
+
+func Actor(userID uint) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(userID), Valid: userID != 0}
+}
+
+// Value serializes v for AuditEvent Before and After fields
+func Value(v any) string {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(bytes)
+}
+
+// balanceValue is balance as recorded in audit events. Keys are read from recorded events, so they never change
+type balanceValue struct {
+	Balance  int64 `json:"balance"`
+	Withdraw int64 `json:"withdraw"`
+	Pending  int64 `json:"pending"`
+	Expired  int64 `json:"expired"`
+}
+
+// Balance serializes balance for AuditEvent Before and After fields
+func Balance(balance *models.Balance) string {
+	return Value(balanceValue{
+		Balance:  balance.Current,
+		Withdraw: balance.Withdrawn,
+		Pending:  balance.Pending,
+		Expired:  balance.Expired,
+	})
+}
@@ -1,12 +1,15 @@
 package auth
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/ksusonic/gophermart/internal/ctxdata"
 	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/problem"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -14,11 +17,20 @@ import (
 
 const defaultJwtKey = "my_secret_key"
 
+// ErrInvalidToken is returned for tokens that are malformed, expired or revoked,
+// other errors of Authenticate mean the token could not be checked
+var ErrInvalidToken = errors.New("invalid token")
+
 type Controller struct {
 	jwtKey []byte
+	db     DB
+}
+
+type DB interface {
+	GetUserByID(ctx context.Context, id uint) (*models.User, error)
 }
 
-func NewAuthController(jwtKey string) *Controller {
+func NewAuthController(jwtKey string, db DB) *Controller {
 	key := defaultJwtKey
 	if jwtKey != "" {
 		key = jwtKey
@@ -26,24 +38,43 @@ func NewAuthController(jwtKey string) *Controller {
 
 	return &Controller{
 		jwtKey: []byte(key),
+		db:     db,
 	}
 }
 
+// AuthMiddleware rejects anonymous requests, it must be used after IdentifyMiddleware
 func (c *Controller) AuthMiddleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		cookie, err := ctx.Cookie("Authorization")
-		if err != nil {
-			ctx.AbortWithStatus(http.StatusUnauthorized)
+		if _, ok := ctxdata.GetUserID(ctx); !ok {
+			_ = ctx.Error(problem.New(http.StatusUnauthorized, problem.CodeUnauthorized))
+			ctx.Abort()
 			return
 		}
+		ctx.Next()
+	}
+}
 
-		claims, err := c.parseToken(cookie)
-		if err != nil {
-			ctx.AbortWithStatus(http.StatusUnauthorized)
+// IdentifyMiddleware sets user of valid token to context, but lets anonymous requests
+// and requests with invalid tokens through. Requests whose token could not be checked fail
+func (c *Controller) IdentifyMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if err := c.identify(ctx); err != nil {
+			_ = ctx.Error(err)
+			ctx.Abort()
 			return
 		}
+		ctx.Next()
+	}
+}
 
-		ctxdata.SetUserID(ctx, claims.UserID)
+// AdminMiddleware must be used after AuthMiddleware
+func (c *Controller) AdminMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !ctxdata.IsAdmin(ctx) {
+			_ = ctx.Error(problem.New(http.StatusForbidden, problem.CodeForbidden))
+			ctx.Abort()
+			return
+		}
 		ctx.Next()
 	}
 }
@@ -66,6 +97,47 @@ func (c *Controller) CreateSignedJWT(claims models.Claims, expiresAt time.Time)
 	return token.SignedString(c.jwtKey)
 }
 
+// Authenticate returns user of token, if token is valid and was not revoked
+func (c *Controller) Authenticate(ctx context.Context, token string) (*models.User, error) {
+	claims, err := c.parseToken(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	// tokens issued before password change are revoked
+	user, err := c.db.GetUserByID(ctx, claims.UserID)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, fmt.Errorf("%w: user %d does not exist", ErrInvalidToken, claims.UserID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get user of token: %w", err)
+	}
+	if user.SessionVersion != claims.SessionVersion {
+		return nil, fmt.Errorf("%w: session of user %d was revoked", ErrInvalidToken, user.ID)
+	}
+	return user, nil
+}
+
+// identify returns error only if token could not be checked
+func (c *Controller) identify(ctx *gin.Context) error {
+	cookie, err := ctx.Cookie("Authorization")
+	if err != nil {
+		return nil
+	}
+
+	user, err := c.Authenticate(ctx.Request.Context(), cookie)
+	if errors.Is(err, ErrInvalidToken) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	ctxdata.SetUserID(ctx, user.ID)
+	ctxdata.SetAdmin(ctx, user.Admin)
+	return nil
+}
+
 func (c *Controller) parseToken(tokenString string) (claims *models.Claims, err error) {
 	token, err := jwt.ParseWithClaims(tokenString, &models.Claims{}, func(token *jwt.Token) (interface{}, error) {
 		return c.jwtKey, nil
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/ctxdata"
+	"github.com/ksusonic/gophermart/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testUserID = 1
+
+type fakeDB struct {
+	user *models.User
+	err  error
+}
+
+func (db fakeDB) GetUserByID(context.Context, uint) (*models.User, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	if db.user == nil {
+		return nil, &models.NotFoundError{Entity: "user"}
+	}
+	return db.user, nil
+}
+
+func TestIdentifyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	user := &models.User{SessionVersion: 1}
+	user.ID = testUserID
+
+	tests := []struct {
+		name       string
+		db         fakeDB
+		token      func(t *testing.T, c *Controller) string
+		wantStatus int
+		wantUser   bool
+	}{
+		{
+			name:       "valid token",
+			db:         fakeDB{user: user},
+			token:      sign(testUserID, 1, time.Hour),
+			wantStatus: http.StatusOK,
+			wantUser:   true,
+		},
+		{
+			name:       "no token",
+			db:         fakeDB{user: user},
+			token:      func(*testing.T, *Controller) string { return "" },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "malformed token",
+			db:         fakeDB{user: user},
+			token:      func(*testing.T, *Controller) string { return "token" },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "expired token",
+			db:         fakeDB{user: user},
+			token:      sign(testUserID, 1, -time.Hour),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "revoked token",
+			db:         fakeDB{user: user},
+			token:      sign(testUserID, 0, time.Hour),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "deleted user",
+			db:         fakeDB{},
+			token:      sign(testUserID, 1, time.Hour),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "database failure",
+			db:         fakeDB{err: errors.New("connection refused")},
+			token:      sign(testUserID, 1, time.Hour),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewAuthController("", tt.db)
+			var gotUser bool
+
+			engine := gin.New()
+			engine.Use(func(ctx *gin.Context) {
+				ctx.Next()
+				if len(ctx.Errors) > 0 {
+					ctx.Status(http.StatusInternalServerError)
+				}
+			})
+			engine.GET("/", c.IdentifyMiddleware(), func(ctx *gin.Context) {
+				_, gotUser = ctxdata.GetUserID(ctx)
+				ctx.Status(http.StatusOK)
+			})
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			if token := tt.token(t, c); token != "" {
+				request.AddCookie(&http.Cookie{Name: "Authorization", Value: token})
+			}
+			recorder := httptest.NewRecorder()
+			engine.ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.wantStatus || gotUser != tt.wantUser {
+				t.Errorf("got status %d and user %v, want %d and %v", recorder.Code, gotUser, tt.wantStatus, tt.wantUser)
+			}
+		})
+	}
+}
+
+func sign(userID, sessionVersion uint, ttl time.Duration) func(t *testing.T, c *Controller) string {
+	return func(t *testing.T, c *Controller) string {
+		t.Helper()
+		token, err := c.CreateSignedJWT(models.Claims{UserID: userID, SessionVersion: sessionVersion}, time.Now().Add(ttl))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"flag"
 	"fmt"
+	"time"
 
 	"github.com/caarlos0/env/v7"
 )
@@ -11,9 +12,67 @@ type Config struct {
 	Address        string `env:"RUN_ADDRESS"`
 	DatabaseURI    string `env:"DATABASE_URI"`
 	AccrualAddress string `env:"ACCRUAL_SYSTEM_ADDRESS"`
+	GRPCAddress    string `env:"GRPC_ADDRESS"` // of gRPC API, empty disables it
 
 	Debug  bool   `env:"DEBUG"`
 	JwtKey string `env:"JWT_TOKEN"`
+
+	AdminLogins []string `env:"ADMIN_LOGINS" envSeparator:","` // granted admin role at startup, must be registered
+
+	DBIsolation string        `env:"DB_ISOLATION" envDefault:"read committed"` // read committed, repeatable read or serializable
+	DBTxRetries int           `env:"DB_TX_RETRIES" envDefault:"3"`             // of transactions failed to serialize or deadlocked
+	DBTimeout   time.Duration `env:"DB_TIMEOUT" envDefault:"5s"`               // of every database call, zero disables
+
+	PasswordMinLength  int    `env:"PASSWORD_MIN_LENGTH" envDefault:"8"`
+	PasswordMinClasses int    `env:"PASSWORD_MIN_CLASSES" envDefault:"1"`
+	PasswordMaxLength  int    `env:"PASSWORD_MAX_LENGTH" envDefault:"0"` // in bytes, zero is the limit of hash algorithm
+	PasswordHash       string `env:"PASSWORD_HASH" envDefault:"bcrypt"`  // bcrypt or argon2id
+	BcryptCost         int    `env:"BCRYPT_COST" envDefault:"12"`
+	Argon2Memory       uint32 `env:"ARGON2_MEMORY" envDefault:"65536"` // KiB
+	Argon2Time         uint32 `env:"ARGON2_TIME" envDefault:"1"`
+	Argon2Threads      uint8  `env:"ARGON2_THREADS" envDefault:"4"`
+
+	ResetTokenTTL     time.Duration `env:"RESET_TOKEN_TTL" envDefault:"30m"`
+	ResetNotifier     string        `env:"RESET_NOTIFIER" envDefault:"log"` // log or file
+	ResetNotifierFile string        `env:"RESET_NOTIFIER_FILE" envDefault:"notifications.log"`
+
+	OrderNumberSchemes string `env:"ORDER_NUMBER_SCHEMES" envDefault:"*=luhn"` // <prefix>=<scheme>;..., schemes: luhn, verhoeff, damm, mod97, none
+
+	AccrualRecheckWindow   time.Duration `env:"ACCRUAL_RECHECK_WINDOW" envDefault:"0"` // zero disables re-verification of processed orders
+	AccrualRecheckInterval time.Duration `env:"ACCRUAL_RECHECK_INTERVAL" envDefault:"10m"`
+
+	PointsTTL            time.Duration `env:"POINTS_TTL" envDefault:"0"` // zero disables expiration
+	PointsExpiryInterval time.Duration `env:"POINTS_EXPIRY_INTERVAL" envDefault:"1h"`
+
+	EventsInterval    time.Duration `env:"EVENTS_INTERVAL" envDefault:"1s"`
+	EventsMaxAttempts int           `env:"EVENTS_MAX_ATTEMPTS" envDefault:"10"`
+	EventsBackoff     time.Duration `env:"EVENTS_BACKOFF" envDefault:"5s"`
+	EventsMaxBackoff  time.Duration `env:"EVENTS_MAX_BACKOFF" envDefault:"10m"`
+
+	WebhookInterval    time.Duration `env:"WEBHOOK_INTERVAL" envDefault:"5s"`
+	WebhookTimeout     time.Duration `env:"WEBHOOK_TIMEOUT" envDefault:"10s"`
+	WebhookMaxAttempts int           `env:"WEBHOOK_MAX_ATTEMPTS" envDefault:"10"`
+	WebhookBackoff     time.Duration `env:"WEBHOOK_BACKOFF" envDefault:"30s"`
+	WebhookMaxBackoff  time.Duration `env:"WEBHOOK_MAX_BACKOFF" envDefault:"1h"`
+	// lets webhooks go over plain http and to private addresses, for local development only
+	WebhookAllowInsecure bool `env:"WEBHOOK_ALLOW_INSECURE"`
+
+	IdempotencyTTL         time.Duration `env:"IDEMPOTENCY_TTL" envDefault:"24h"`
+	IdempotencyLockTimeout time.Duration `env:"IDEMPOTENCY_LOCK_TIMEOUT" envDefault:"1m"`
+
+	LoginMaxAttempts   int           `env:"LOGIN_MAX_ATTEMPTS" envDefault:"5"`
+	LoginMaxIPAttempts int           `env:"LOGIN_MAX_IP_ATTEMPTS" envDefault:"20"`
+	LoginLockout       time.Duration `env:"LOGIN_LOCKOUT" envDefault:"30s"`
+	LoginMaxLockout    time.Duration `env:"LOGIN_MAX_LOCKOUT" envDefault:"15m"`
+	LoginFailureWindow time.Duration `env:"LOGIN_FAILURE_WINDOW" envDefault:"15m"`
+
+	APIV1Sunset time.Time `env:"API_V1_SUNSET"` // RFC 3339, announced in Sunset header of v1 routes
+
+	OpenAPIValidate bool `env:"OPENAPI_VALIDATE" envDefault:"false"` // check requests and responses against spec, costs a copy of every response
+
+	RateLimitStore   string `env:"RATE_LIMIT_STORE" envDefault:"memory"`                                                        // memory or postgres
+	RateLimitDefault string `env:"RATE_LIMIT_DEFAULT" envDefault:"20:40"`                                                       // <rate per second>:<burst>
+	RateLimitRoutes  string `env:"RATE_LIMIT_ROUTES" envDefault:"POST /api/user/orders=1:10;POST /api/user/orders/batch=0.1:3"` // <METHOD> <path> or gRPC method=<limit>;...
 }
 
 func NewConfig() (*Config, error) {
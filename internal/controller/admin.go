@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/api"
+	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const defaultAuditLimit = 100
+
+type AdminController struct {
+	Controller
+
+	auth     AuthController
+	balance  BalanceService
+	webhooks WebhookService
+}
+
+func NewAdminController(
+	auth AuthController,
+	balance BalanceService,
+	webhooks WebhookService,
+	db Database,
+	logger *zap.SugaredLogger,
+) *AdminController {
+	return &AdminController{
+		Controller: Controller{
+			DB:     db,
+			Logger: logger,
+		},
+		auth:     auth,
+		balance:  balance,
+		webhooks: webhooks,
+	}
+}
+
+func (c *AdminController) RegisterHandlers(router *gin.RouterGroup) {
+	router.Use(c.auth.AuthMiddleware(), c.auth.AdminMiddleware())
+
+	router.GET("/audit", c.auditHandler)
+	router.GET("/adjustments", c.adjustmentsHandler)
+	router.POST("/withdrawals/:order/confirm", c.withdrawalConfirmHandler)
+	router.POST("/withdrawals/:order/cancel", c.withdrawalCancelHandler)
+	router.POST("/withdrawals/:order/reverse", c.withdrawalReverseHandler)
+	router.GET("/webhooks", c.webhookListHandler)
+	router.POST("/webhooks", c.webhookCreateHandler)
+	router.DELETE("/webhooks/:id", c.webhookDeleteHandler)
+	router.GET("/webhooks/:id/deliveries", c.webhookDeliveriesHandler)
+}
+
+func (c *AdminController) auditHandler(ctx *gin.Context) {
+	var query api.AuditQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		c.invalidRequest(ctx, err)
+		return
+	}
+
+	filter := models.AuditEventFilter{
+		Action: models.AuditAction(query.Action),
+		Target: query.Target,
+		From:   query.From,
+		To:     query.To,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	}
+	if query.ActorID != nil {
+		filter.ActorID = sql.NullInt64{Int64: int64(*query.ActorID), Valid: true}
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultAuditLimit
+	}
+
+	events, err := c.DB.GetAuditEvents(ctx.Request.Context(), filter)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	response := make([]api.AuditEvent, len(*events))
+	for i, event := range *events {
+		response[i] = api.AuditEvent{
+			ID:        event.ID,
+			CreatedAt: event.CreatedAt.Format(time.RFC3339),
+			Action:    string(event.Action),
+			Target:    event.Target,
+			IP:        event.IP,
+			UserAgent: event.UserAgent,
+			Before:    rawJSON(event.Before),
+			After:     rawJSON(event.After),
+		}
+		if event.ActorID.Valid {
+			actorID := event.ActorID.Int64
+			response[i].ActorID = &actorID
+		}
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (c *AdminController) adjustmentsHandler(ctx *gin.Context) {
+	var query api.AdjustmentQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		c.invalidRequest(ctx, err)
+		return
+	}
+
+	adjustments, err := c.DB.GetAccrualAdjustments(ctx.Request.Context(), query.Flagged)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	response := make([]api.AccrualAdjustment, len(*adjustments))
+	for i, adjustment := range *adjustments {
+		response[i] = api.AccrualAdjustment{
+			ID:           adjustment.ID,
+			CreatedAt:    adjustment.CreatedAt.Format(time.RFC3339),
+			Order:        adjustment.OrderID,
+			UserID:       adjustment.UserID,
+			Before:       float64(adjustment.Before) / 100,
+			After:        float64(adjustment.After) / 100,
+			Delta:        float64(adjustment.Delta) / 100,
+			BalanceAfter: float64(adjustment.BalanceAfter) / 100,
+			Flagged:      adjustment.Flagged,
+		}
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (c *AdminController) withdrawalConfirmHandler(ctx *gin.Context) {
+	adminID, ok := c.adminID(ctx)
+	if !ok {
+		return
+	}
+
+	transitionWithdrawal(ctx, c.balance, service.TransitionInput{
+		ActorID: adminID,
+		From:    []models.WithdrawalStatus{models.WithdrawalStatusPending},
+		To:      models.WithdrawalStatusConfirmed,
+	})
+}
+
+func (c *AdminController) withdrawalCancelHandler(ctx *gin.Context) {
+	adminID, ok := c.adminID(ctx)
+	if !ok {
+		return
+	}
+
+	transitionWithdrawal(ctx, c.balance, service.TransitionInput{
+		ActorID: adminID,
+		From:    []models.WithdrawalStatus{models.WithdrawalStatusPending},
+		To:      models.WithdrawalStatusCancelled,
+	})
+}
+
+func (c *AdminController) withdrawalReverseHandler(ctx *gin.Context) {
+	adminID, ok := c.adminID(ctx)
+	if !ok {
+		return
+	}
+
+	transitionWithdrawal(ctx, c.balance, service.TransitionInput{
+		ActorID: adminID,
+		From:    []models.WithdrawalStatus{models.WithdrawalStatusConfirmed},
+		To:      models.WithdrawalStatusReversed,
+	})
+}
+
+// adminID returns admin acting in request, or fails the request if it is unknown
+func (c *AdminController) adminID(ctx *gin.Context) (uint, bool) {
+	adminID, err := c.auth.GetUserID(ctx)
+	if err != nil {
+		_ = ctx.Error(err)
+		return 0, false
+	}
+	return adminID, true
+}
+
+func rawJSON(value string) json.RawMessage {
+	if value == "" {
+		return nil
+	}
+	return json.RawMessage(value)
+}
@@ -1,8 +1,10 @@
 package controller
 
 import (
-	"github.com/ksusonic/gophermart/internal/api"
+	"context"
+
 	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/service"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -17,13 +19,15 @@ func (c Controller) RegisterHandlers(*gin.RouterGroup) {
 	panic("not implemented!")
 }
 
-type Database interface {
-	CreateUser(user *models.User) error
-	CreateOrder(order *models.Order) error
+// client describes caller of request for services
+func client(ctx *gin.Context) service.Client {
+	return service.Client{IP: ctx.ClientIP(), UserAgent: ctx.Request.UserAgent()}
+}
 
-	GetUserByLogin(login string) (*models.User, error)
-	GetOrderByID(id string) (*models.Order, error)
-	GetWithdrawnOrdersByUserID(userID uint) (*[]models.Order, error)
-	GetOrdersByUserID(userID uint) (*[]models.Order, error)
-	CalculateUserStats(userID uint) (*api.UserInfo, error)
+type Database interface {
+	GetAuditEvents(ctx context.Context, filter models.AuditEventFilter) (*[]models.AuditEvent, error)
+	GetAccrualAdjustments(ctx context.Context, flaggedOnly bool) (*[]models.AccrualAdjustment, error)
+	GetWebhookSubscriptions(ctx context.Context) (*[]models.WebhookSubscription, error)
+	GetWebhookSubscriptionByID(ctx context.Context, id uint) (*models.WebhookSubscription, error)
+	GetWebhookDeliveries(ctx context.Context, subscriptionID uint, limit int) (*[]models.WebhookDelivery, error)
 }
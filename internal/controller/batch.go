@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ksusonic/gophermart/internal/api"
+	"github.com/ksusonic/gophermart/internal/ctxdata"
+	"github.com/ksusonic/gophermart/internal/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ordersBatchHandler accepts JSON array or newline separated list of order numbers.
+// Other content types are rejected, so that a mislabeled array is not taken for lines
+func (c *UserController) ordersBatchHandler(ctx *gin.Context) {
+	userID, err := c.auth.GetUserID(ctx)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	var parse func(body []byte) ([]string, error)
+	switch contentType := ctx.ContentType(); contentType {
+	case gin.MIMEJSON:
+		parse = parseJSONNumbers
+	case gin.MIMEPlain:
+		parse = parseTextNumbers
+	default:
+		_ = ctx.Error(problem.New(http.StatusUnsupportedMediaType, problem.CodeUnsupportedContent, contentType))
+		return
+	}
+
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		c.invalidRequest(ctx, err)
+		return
+	}
+
+	numbers, err := parse(body)
+	if err != nil {
+		ctxdata.Logger(ctx.Request.Context(), c.Logger).Debugf("could not parse order numbers: %v", err)
+		_ = ctx.Error(problem.New(http.StatusBadRequest, problem.CodeOrderBatchInvalid))
+		return
+	}
+
+	results, err := c.orders.UploadBatch(ctx.Request.Context(), userID, numbers)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, api.BatchOrders(results))
+}
+
+// parseJSONNumbers accepts both strings and numbers, numbers are kept as written
+func parseJSONNumbers(body []byte) ([]string, error) {
+	var values []json.RawMessage
+	if err := json.Unmarshal(body, &values); err != nil {
+		return nil, fmt.Errorf("expected JSON array of order numbers")
+	}
+
+	numbers := make([]string, len(values))
+	for i, value := range values {
+		var number string
+		if err := json.Unmarshal(value, &number); err == nil {
+			numbers[i] = strings.TrimSpace(number)
+			continue
+		}
+		var raw json.Number
+		if err := json.Unmarshal(value, &raw); err != nil {
+			return nil, fmt.Errorf("element %d is neither string nor number", i)
+		}
+		numbers[i] = raw.String()
+	}
+	return numbers, nil
+}
+
+func parseTextNumbers(body []byte) ([]string, error) {
+	var numbers []string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		if number := strings.TrimSpace(scanner.Text()); number != "" {
+			numbers = append(numbers, number)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read order numbers: %w", err)
+	}
+	return numbers, nil
+}
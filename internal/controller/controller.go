@@ -1,318 +1,326 @@
 package controller
 
 import (
-	"database/sql"
+	"context"
 	"errors"
 	"io"
+	"math"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/ksusonic/gophermart/internal/api"
+	"github.com/ksusonic/gophermart/internal/ctxdata"
 	"github.com/ksusonic/gophermart/internal/models"
-	"github.com/ksusonic/gophermart/internal/utils"
+	"github.com/ksusonic/gophermart/internal/problem"
+	"github.com/ksusonic/gophermart/internal/service"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
-const defaultTokenTTL = 120 * time.Minute
-
 type UserController struct {
 	Controller
 
-	auth AuthController
+	auth        AuthController
+	idempotency Idempotency
+	users       UserService
+	orders      OrderService
+	balance     BalanceService
 }
 
 type AuthController interface {
 	AuthMiddleware() gin.HandlerFunc
-	CreateSignedJWT(claims models.Claims, expiresAt time.Time) (string, error)
+	AdminMiddleware() gin.HandlerFunc
 	GetUserID(ctx *gin.Context) (uint, error)
 }
 
-func NewUserController(auth AuthController, db Database, logger *zap.SugaredLogger) *UserController {
+type Idempotency interface {
+	Handler() gin.HandlerFunc
+}
+
+type UserService interface {
+	Register(ctx context.Context, credentials service.Credentials, client service.Client) (*service.Session, error)
+	Login(ctx context.Context, credentials service.Credentials, client service.Client) (*service.Session, error)
+	ChangePassword(ctx context.Context, userID uint, current, password string, client service.Client) (*service.Session, error)
+	RequestPasswordReset(ctx context.Context, login string, client service.Client)
+	ResetPassword(ctx context.Context, token, password string, client service.Client) error
+}
+
+type OrderService interface {
+	Upload(ctx context.Context, userID uint, number string) (created bool, err error)
+	UploadBatch(ctx context.Context, userID uint, numbers []string) ([]service.BatchResult, error)
+	List(ctx context.Context, userID uint, page models.Page) (orders []models.Order, hasMore bool, err error)
+}
+
+type WebhookService interface {
+	Subscribe(ctx context.Context, input service.SubscribeInput, client service.Client) (*models.WebhookSubscription, error)
+	Unsubscribe(ctx context.Context, actorID, id uint, client service.Client) error
+}
+
+type BalanceService interface {
+	Balance(ctx context.Context, userID uint) (*models.Balance, error)
+	Expiring(ctx context.Context, userID uint) ([]models.ExpiringPoints, error)
+	Withdraw(ctx context.Context, input service.WithdrawInput, client service.Client) (*models.Balance, error)
+	Withdrawals(ctx context.Context, userID uint, page models.Page) (withdrawals []models.Withdrawal, hasMore bool, err error)
+	TransitionWithdrawal(ctx context.Context, input service.TransitionInput, client service.Client) (*models.Withdrawal, error)
+}
+
+func NewUserController(
+	auth AuthController,
+	idempotency Idempotency,
+	users UserService,
+	orders OrderService,
+	balance BalanceService,
+	logger *zap.SugaredLogger,
+) *UserController {
 	return &UserController{
 		Controller: Controller{
-			DB:     db,
 			Logger: logger,
 		},
-		auth: auth,
+		auth:        auth,
+		idempotency: idempotency,
+		users:       users,
+		orders:      orders,
+		balance:     balance,
 	}
 }
 
 func (c *UserController) RegisterHandlers(router *gin.RouterGroup) {
 	router.POST("/register", c.registerHandler)
 	router.POST("/login", c.loginHandler)
+	router.POST("/password/reset", c.passwordResetRequestHandler)
+	router.POST("/password/reset/confirm", c.passwordResetConfirmHandler)
 
 	authOnly := router.Group("")
 	authOnly.Use(c.auth.AuthMiddleware())
 
-	authOnly.POST("/orders", c.ordersPostHandler)
+	// errors are rendered inside idempotency, so replayed responses contain them
+	idempotent := func(handler gin.HandlerFunc) []gin.HandlerFunc {
+		return []gin.HandlerFunc{c.idempotency.Handler(), ErrorHandler(c.Logger), handler}
+	}
+
+	authOnly.POST("/orders", idempotent(c.ordersPostHandler)...)
+	authOnly.POST("/orders/batch", idempotent(c.ordersBatchHandler)...)
 	authOnly.GET("/orders", c.ordersGetHandler)
 	authOnly.GET("/balance", c.balanceHandler)
-	authOnly.POST("/balance/withdraw", c.balanceWithdrawHandler)
+	authOnly.GET("/balance/expiring", c.balanceExpiringHandler)
+	authOnly.POST("/balance/withdraw", idempotent(c.balanceWithdrawHandler)...)
 	authOnly.GET("/withdrawals", c.withdrawalsHandler)
+	authOnly.POST("/withdrawals/:order/cancel", c.withdrawalCancelHandler)
+	authOnly.POST("/password", c.passwordChangeHandler)
 }
 
 func (c *UserController) registerHandler(ctx *gin.Context) {
 	var request api.User
 
 	if err := ctx.ShouldBindJSON(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.invalidRequest(ctx, err)
 		return
 	}
 
-	existingUser, err := c.DB.GetUserByLogin(request.Login)
-	if renderIfEntityError(ctx, err, c.Logger) {
+	session, err := c.users.Register(ctx.Request.Context(), service.Credentials{
+		Login:    request.Login,
+		Password: request.Password,
+	}, client(ctx))
+	if errors.Is(err, models.ErrConflict) {
+		_ = ctx.Error(problem.New(http.StatusConflict, problem.CodeUserExists))
 		return
 	}
-
-	if existingUser.ID != 0 {
-		ctx.JSON(http.StatusConflict, gin.H{"error": "user already exists"})
-		return
-	}
-
-	hashedPassword, err := utils.GenerateHashPassword(request.Password)
 	if err != nil {
-		c.Logger.Warnf("could not hash password: %v", err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "could not generate password hash"})
+		_ = ctx.Error(err)
 		return
 	}
 
-	user := models.User{
-		Login:        request.Login,
-		PasswordHash: hashedPassword,
-	}
-	err = c.DB.CreateUser(&user)
-	if renderIfEntityError(ctx, err, c.Logger) {
-		return
-	}
-
-	expiresAt := time.Now().Add(defaultTokenTTL)
-	signedToken, err := c.auth.CreateSignedJWT(models.Claims{
-		UserID: user.ID,
-	}, expiresAt)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "could not generate token"})
-		return
-	}
-
-	ctx.SetCookie("Authorization", signedToken, int(expiresAt.Unix()), "/", "", false, true)
-	ctx.JSON(http.StatusOK, gin.H{"status": "welcome"})
+	setAuthCookie(ctx, session)
+	ctx.JSON(http.StatusOK, api.StatusResponse{Status: "welcome"})
 }
 
 func (c *UserController) loginHandler(ctx *gin.Context) {
 	var request api.User
 
 	if err := ctx.ShouldBindJSON(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	existingUser, err := c.DB.GetUserByLogin(request.Login)
-	if renderIfEntityError(ctx, err, c.Logger) {
-		return
-	}
-	if errors.Is(err, sql.ErrNoRows) {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "user does not exist"})
-		return
-	}
-
-	errHash := utils.CompareHashPassword(request.Password, existingUser.PasswordHash)
-	if !errHash {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid password"})
+		c.invalidRequest(ctx, err)
 		return
 	}
 
-	expiresAt := time.Now().Add(defaultTokenTTL)
-	signedToken, err := c.auth.CreateSignedJWT(models.Claims{
-		UserID: existingUser.ID,
-	}, expiresAt)
+	session, err := c.users.Login(ctx.Request.Context(), service.Credentials{
+		Login:    request.Login,
+		Password: request.Password,
+	}, client(ctx))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "could not generate token"})
+		setRetryAfter(ctx, err)
+		_ = ctx.Error(err)
 		return
 	}
 
-	ctx.SetCookie("Authorization", signedToken, int(expiresAt.Unix()), "/", "", false, true)
-	ctx.JSON(http.StatusOK, gin.H{"success": "logged in"})
+	setAuthCookie(ctx, session)
+	ctx.JSON(http.StatusOK, api.StatusResponse{Status: "logged in"})
 }
 
 func (c *UserController) ordersPostHandler(ctx *gin.Context) {
 	userID, err := c.auth.GetUserID(ctx)
 	if err != nil {
-		c.Logger.Errorf("not found user_id in context: %s %s", ctx.Request.Method, ctx.Request.RequestURI)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "internal service error"})
+		_ = ctx.Error(err)
 		return
 	}
 
 	body, err := io.ReadAll(ctx.Request.Body)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "incorrect body"})
-		return
-	}
-	orderNumber, err := strconv.ParseInt(string(body), 10, 64)
-	if err != nil {
-		c.Logger.Error("could not bind request:", err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "wrong order format"})
+		c.invalidRequest(ctx, err)
 		return
 	}
 
-	if !utils.LuhnValid(orderNumber) {
-		c.Logger.Info("luhn-invalid number:", orderNumber)
-		ctx.JSON(
-			http.StatusUnprocessableEntity,
-			gin.H{"error": "incorrect order number: " + strconv.FormatInt(orderNumber, 10)},
-		)
+	created, err := c.orders.Upload(ctx.Request.Context(), userID, string(body))
+	if errors.Is(err, models.ErrConflict) {
+		_ = ctx.Error(problem.New(http.StatusConflict, problem.CodeOrderConflict))
 		return
 	}
-
-	existingOrder, err := c.DB.GetOrderByID(strconv.FormatInt(orderNumber, 10))
-	if renderIfEntityError(ctx, err, c.Logger) {
+	if err != nil {
+		_ = ctx.Error(err)
 		return
 	}
 
-	if errors.Is(err, sql.ErrNoRows) {
-		// create new order
-		err = c.DB.CreateOrder(&models.Order{
-			ID:     strconv.FormatInt(orderNumber, 10),
-			UserID: userID,
-			Status: models.OrderStatusNew,
-		})
-		if renderIfEntityError(ctx, err, c.Logger) {
-			return
-		}
-		ctx.JSON(http.StatusAccepted, gin.H{"status": "accepted"})
-		return
-	} else if existingOrder.UserID == userID {
-		ctx.JSON(http.StatusOK, gin.H{"status": "already accepted"})
-		return
+	if created {
+		ctx.JSON(http.StatusAccepted, api.StatusResponse{Status: "accepted"})
 	} else {
-		ctx.JSON(http.StatusConflict, gin.H{"status": "already accepted by another user"})
-		return
+		ctx.JSON(http.StatusOK, api.StatusResponse{Status: "already accepted"})
 	}
-
 }
 
 func (c *UserController) ordersGetHandler(ctx *gin.Context) {
 	userID, err := c.auth.GetUserID(ctx)
 	if err != nil {
-		c.Logger.Errorf("not found user_id in context: %s %s", ctx.Request.Method, ctx.Request.RequestURI)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "internal service error"})
+		_ = ctx.Error(err)
 		return
 	}
 
-	orders, err := c.DB.GetOrdersByUserID(userID)
-	if renderIfEntityError(ctx, err, c.Logger) {
+	var query api.PageQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		c.invalidRequest(ctx, err)
 		return
 	}
 
-	response := make([]api.Order, len(*orders))
-	for i := range *orders {
-		response[i] = api.Order{
-			Number:     (*orders)[i].ID,
-			Status:     (*orders)[i].Status,
-			UploadedAt: (*orders)[i].CreatedAt.Format(time.RFC3339),
-		}
-		if (*orders)[i].Accrual.Valid {
-			response[i].Accrual = float64((*orders)[i].Accrual.Int64) / 100
-		}
+	mapper := api.MapperFor(api.VersionOf(ctx))
+	page := mapper.Page(query)
+	orders, hasMore, err := c.orders.List(ctx.Request.Context(), userID, page)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
 	}
-
-	ctx.JSON(http.StatusOK, response)
+	if len(orders) == 0 && mapper.NoContent() {
+		ctx.Status(http.StatusNoContent)
+		return
+	}
+	ctx.JSON(http.StatusOK, mapper.Orders(orders, page, hasMore))
 }
 
 func (c *UserController) balanceHandler(ctx *gin.Context) {
 	userID, err := c.auth.GetUserID(ctx)
 	if err != nil {
-		c.Logger.Errorf("not found user_id in context: %s %s", ctx.Request.Method, ctx.Request.RequestURI)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "internal service error"})
+		_ = ctx.Error(err)
 		return
 	}
 
-	userInfo, err := c.DB.CalculateUserStats(userID)
-	if renderIfEntityError(ctx, err, c.Logger) {
+	balance, err := c.balance.Balance(ctx.Request.Context(), userID)
+	if err != nil {
+		_ = ctx.Error(err)
 		return
 	}
 
-	c.Logger.Debugf("currently user %d has %f and withdrawn %f", userID, userInfo.Balance, userInfo.Withdraw)
+	ctxdata.Logger(ctx.Request.Context(), c.Logger).Debugf("currently user %d has %d and withdrawn %d", userID, balance.Current, balance.Withdrawn)
 
-	ctx.JSON(http.StatusOK, api.BalanceResponse{
-		Current:   float64(userInfo.Balance) / 100,
-		Withdrawn: float64(userInfo.Withdraw) / 100,
-	})
+	ctx.JSON(http.StatusOK, api.MapperFor(api.VersionOf(ctx)).Balance(balance))
 }
 
-func (c *UserController) balanceWithdrawHandler(ctx *gin.Context) {
+func (c *UserController) balanceExpiringHandler(ctx *gin.Context) {
 	userID, err := c.auth.GetUserID(ctx)
 	if err != nil {
-		c.Logger.Errorf("not found user_id in context: %s %s", ctx.Request.Method, ctx.Request.RequestURI)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "internal service error"})
+		_ = ctx.Error(err)
 		return
 	}
 
-	var request api.WithdrawRequest
-	if err := ctx.ShouldBindJSON(&request); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	upcoming, err := c.balance.Expiring(ctx.Request.Context(), userID)
+	if err != nil {
+		_ = ctx.Error(err)
 		return
 	}
-
-	var order = models.Order{
-		ID:     request.Order,
-		UserID: userID,
-		Status: models.OrderStatusNew,
-		Withdraw: sql.NullInt64{
-			Int64: int64(request.Sum * 100),
-			Valid: true,
-		},
+	mapper := api.MapperFor(api.VersionOf(ctx))
+	if len(upcoming) == 0 && mapper.NoContent() {
+		ctx.Status(http.StatusNoContent)
+		return
 	}
+	ctx.JSON(http.StatusOK, mapper.ExpiringPoints(upcoming))
+}
 
-	_, err = c.DB.GetOrderByID(order.ID)
-	if !errors.Is(err, sql.ErrNoRows) {
-		ctx.JSON(http.StatusConflict, gin.H{"error": "order already exists"})
+func (c *UserController) balanceWithdrawHandler(ctx *gin.Context) {
+	userID, err := c.auth.GetUserID(ctx)
+	if err != nil {
+		_ = ctx.Error(err)
 		return
-	} else if renderIfEntityError(ctx, err, c.Logger) {
+	}
+
+	body := api.MapperFor(api.VersionOf(ctx)).WithdrawalRequest()
+	if err := ctx.ShouldBindJSON(body); err != nil {
+		c.invalidRequest(ctx, err)
 		return
 	}
+	request := body.Input()
 
-	err = c.DB.CreateOrder(&order)
-	if renderIfEntityError(ctx, err, c.Logger) {
+	_, err = c.balance.Withdraw(ctx.Request.Context(), service.WithdrawInput{
+		UserID: userID,
+		Order:  request.Order,
+		Sum:    request.Sum,
+		Hold:   request.Hold,
+	}, client(ctx))
+	if errors.Is(err, models.ErrConflict) {
+		_ = ctx.Error(problem.New(http.StatusConflict, problem.CodeWithdrawalExists))
+		return
+	}
+	if err != nil {
+		_ = ctx.Error(err)
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"status": "ok - withdrawn"})
+	ctx.JSON(http.StatusOK, api.StatusResponse{Status: "ok - withdrawn"})
 }
 
 func (c *UserController) withdrawalsHandler(ctx *gin.Context) {
 	userID, err := c.auth.GetUserID(ctx)
 	if err != nil {
-		c.Logger.Errorf("not found user_id in context: %s %s", ctx.Request.Method, ctx.Request.RequestURI)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "internal service error"})
+		_ = ctx.Error(err)
 		return
 	}
 
-	withdrawals, err := c.DB.GetWithdrawnOrdersByUserID(userID)
-	if renderIfEntityError(ctx, err, c.Logger) {
+	var query api.PageQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		c.invalidRequest(ctx, err)
 		return
 	}
 
-	var response = make(api.WithdrawResponse, len(*withdrawals))
-	for i := range *withdrawals {
-		response[i] = api.Withdraw{
-			Order:       (*withdrawals)[i].ID,
-			Sum:         float64((*withdrawals)[i].Withdraw.Int64) / 100,
-			ProcessedAt: (*withdrawals)[i].UpdatedAt.Format(time.RFC3339),
-		}
+	mapper := api.MapperFor(api.VersionOf(ctx))
+	page := mapper.Page(query)
+	withdrawals, hasMore, err := c.balance.Withdrawals(ctx.Request.Context(), userID, page)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	if len(withdrawals) == 0 && mapper.NoContent() {
+		ctx.Status(http.StatusNoContent)
+		return
 	}
-	ctx.JSON(http.StatusOK, response)
+	ctx.JSON(http.StatusOK, mapper.Withdrawals(withdrawals, page, hasMore))
 }
 
-func renderIfEntityError(ctx *gin.Context, err error, logger *zap.SugaredLogger) bool {
-	if errors.Is(err, sql.ErrNoRows) {
-		return false
-	}
-	if err != nil {
-		logger.Errorf("entity error: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "internal service error"})
-		return true
+// setAuthCookie keeps token of session in cookie
+func setAuthCookie(ctx *gin.Context, session *service.Session) {
+	maxAge := int(time.Until(session.ExpiresAt).Seconds())
+	ctx.SetCookie("Authorization", session.Token, maxAge, "/", "", false, true)
+}
+
+// setRetryAfter tells client when locked login may be retried
+func setRetryAfter(ctx *gin.Context, err error) {
+	var locked *service.LockedError
+	if errors.As(err, &locked) {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
 	}
-	return false
 }
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ksusonic/gophermart/internal/ctxdata"
+	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/password"
+	"github.com/ksusonic/gophermart/internal/problem"
+	"github.com/ksusonic/gophermart/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ErrorHandler renders the last error added with ctx.Error as problem+json,
+// unless response has already been written
+func ErrorHandler(logger *zap.SugaredLogger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		if len(ctx.Errors) == 0 || ctx.Writer.Written() {
+			return
+		}
+		err := ctx.Errors.Last().Err
+		p := toProblem(err)
+		if p.Status >= http.StatusInternalServerError {
+			ctxdata.Logger(ctx.Request.Context(), logger).Errorf("%s %s: %v", ctx.Request.Method, ctx.FullPath(), err)
+		}
+		problem.Render(ctx, p)
+	}
+}
+
+// toProblem maps domain errors to problems, anything unknown is internal error
+func toProblem(err error) *problem.Error {
+	var (
+		p             *problem.Error
+		locked        *service.LockedError
+		notFound      *models.NotFoundError
+		invalidNumber *models.InvalidNumberError
+		policy        *password.PolicyError
+	)
+	switch {
+	case errors.As(err, &p):
+		return p
+	case errors.As(err, &invalidNumber):
+		return problem.New(http.StatusUnprocessableEntity, problem.CodeOrderInvalidNumber, invalidNumber.Number)
+	case errors.Is(err, models.ErrInsufficientFunds):
+		return problem.New(http.StatusPaymentRequired, problem.CodeBalanceInsufficient)
+	case errors.As(err, &notFound):
+		switch notFound.Entity {
+		case "withdrawal":
+			return problem.New(http.StatusNotFound, problem.CodeWithdrawalNotFound)
+		case "webhook subscription":
+			return problem.New(http.StatusNotFound, problem.CodeWebhookNotFound)
+		}
+		return problem.New(http.StatusNotFound, problem.CodeNotFound)
+	case errors.Is(err, models.ErrConflict):
+		return problem.New(http.StatusConflict, problem.CodeConflict)
+	case errors.As(err, &policy):
+		return passwordProblem(policy)
+	case errors.Is(err, service.ErrLoginRequired):
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest)
+	case errors.Is(err, service.ErrInvalidCredentials):
+		return problem.New(http.StatusUnauthorized, problem.CodeInvalidCredentials)
+	case errors.As(err, &locked):
+		return problem.New(http.StatusTooManyRequests, problem.CodeLoginLocked)
+	case errors.Is(err, service.ErrInvalidResetToken):
+		return problem.New(http.StatusBadRequest, problem.CodePasswordInvalidToken)
+	case errors.Is(err, service.ErrInvalidOrderFormat):
+		return problem.New(http.StatusBadRequest, problem.CodeOrderInvalidFormat)
+	case errors.Is(err, service.ErrBatchEmpty):
+		return problem.New(http.StatusBadRequest, problem.CodeOrderBatchEmpty)
+	case errors.Is(err, service.ErrBatchTooLarge):
+		return problem.New(http.StatusBadRequest, problem.CodeOrderBatchTooLarge, service.MaxBatchSize)
+	case errors.Is(err, service.ErrInvalidPage):
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest)
+	case errors.Is(err, service.ErrInvalidSum):
+		return problem.New(http.StatusBadRequest, problem.CodeWithdrawalInvalidSum)
+	case errors.Is(err, service.ErrWebhookURL):
+		return problem.New(http.StatusBadRequest, problem.CodeWebhookInvalidURL)
+	}
+	return problem.New(http.StatusInternalServerError, problem.CodeInternal)
+}
+
+func passwordProblem(err *password.PolicyError) *problem.Error {
+	switch err.Err {
+	case password.ErrTooShort:
+		return problem.New(http.StatusBadRequest, problem.CodePasswordTooShort, err.Limit)
+	case password.ErrTooLong:
+		return problem.New(http.StatusBadRequest, problem.CodePasswordTooLong, err.Limit)
+	case password.ErrTooSimple:
+		return problem.New(http.StatusBadRequest, problem.CodePasswordTooSimple, err.Limit)
+	case password.ErrCommon:
+		return problem.New(http.StatusBadRequest, problem.CodePasswordCommon)
+	case password.ErrSameAsLogin:
+		return problem.New(http.StatusBadRequest, problem.CodePasswordSameAsLogin)
+	}
+	return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest)
+}
+
+// invalidRequest hides binding details from client, they are only logged
+func (c Controller) invalidRequest(ctx *gin.Context, err error) {
+	ctxdata.Logger(ctx.Request.Context(), c.Logger).Debugf("invalid request %s %s: %v", ctx.Request.Method, ctx.FullPath(), err)
+	_ = ctx.Error(problem.New(http.StatusBadRequest, problem.CodeInvalidRequest))
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/ksusonic/gophermart/internal/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (c *UserController) passwordChangeHandler(ctx *gin.Context) {
+	userID, err := c.auth.GetUserID(ctx)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	var request api.PasswordChangeRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		c.invalidRequest(ctx, err)
+		return
+	}
+
+	session, err := c.users.ChangePassword(ctx.Request.Context(), userID, request.CurrentPassword, request.NewPassword, client(ctx))
+	if err != nil {
+		setRetryAfter(ctx, err)
+		_ = ctx.Error(err)
+		return
+	}
+
+	// other sessions are revoked, current one gets a fresh token
+	setAuthCookie(ctx, session)
+	ctx.JSON(http.StatusOK, api.StatusResponse{Status: "password changed"})
+}
+
+func (c *UserController) passwordResetRequestHandler(ctx *gin.Context) {
+	var request api.PasswordResetRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		c.invalidRequest(ctx, err)
+		return
+	}
+
+	c.users.RequestPasswordReset(ctx.Request.Context(), request.Login, client(ctx))
+	ctx.JSON(http.StatusAccepted, api.StatusResponse{Status: "reset requested"})
+}
+
+func (c *UserController) passwordResetConfirmHandler(ctx *gin.Context) {
+	var request api.PasswordResetConfirmRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		c.invalidRequest(ctx, err)
+		return
+	}
+
+	if err := c.users.ResetPassword(ctx.Request.Context(), request.Token, request.NewPassword, client(ctx)); err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, api.StatusResponse{Status: "password changed"})
+}
@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/api"
+	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/problem"
+	"github.com/ksusonic/gophermart/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultDeliveriesLimit = 50
+
+func (c *AdminController) webhookCreateHandler(ctx *gin.Context) {
+	var request api.WebhookSubscriptionRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		c.invalidRequest(ctx, err)
+		return
+	}
+	for _, event := range request.Events {
+		if !knownWebhookEvent(event) {
+			_ = ctx.Error(problem.New(http.StatusBadRequest, problem.CodeWebhookUnknownEvent, event))
+			return
+		}
+	}
+	adminID, ok := c.adminID(ctx)
+	if !ok {
+		return
+	}
+
+	subscription, err := c.webhooks.Subscribe(ctx.Request.Context(), service.SubscribeInput{
+		ActorID: adminID,
+		Partner: request.Partner,
+		URL:     request.URL,
+		Secret:  request.Secret,
+		Events:  request.Events,
+	}, client(ctx))
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	response := webhookSubscriptionResponse(subscription)
+	response.Secret = subscription.Secret
+	ctx.JSON(http.StatusCreated, response)
+}
+
+func (c *AdminController) webhookListHandler(ctx *gin.Context) {
+	subscriptions, err := c.DB.GetWebhookSubscriptions(ctx.Request.Context())
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	response := make([]api.WebhookSubscription, len(*subscriptions))
+	for i := range *subscriptions {
+		response[i] = webhookSubscriptionResponse(&(*subscriptions)[i])
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (c *AdminController) webhookDeleteHandler(ctx *gin.Context) {
+	id, ok := webhookID(ctx)
+	if !ok {
+		return
+	}
+	adminID, ok := c.adminID(ctx)
+	if !ok {
+		return
+	}
+
+	err := c.webhooks.Unsubscribe(ctx.Request.Context(), adminID, id, client(ctx))
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (c *AdminController) webhookDeliveriesHandler(ctx *gin.Context) {
+	id, ok := webhookID(ctx)
+	if !ok {
+		return
+	}
+
+	_, err := c.DB.GetWebhookSubscriptionByID(ctx.Request.Context(), id)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	deliveries, err := c.DB.GetWebhookDeliveries(ctx.Request.Context(), id, defaultDeliveriesLimit)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	response := make([]api.WebhookDelivery, len(*deliveries))
+	for i, delivery := range *deliveries {
+		response[i] = api.WebhookDelivery{
+			ID:        delivery.ID,
+			CreatedAt: delivery.CreatedAt.Format(time.RFC3339),
+			Event:     string(delivery.Event),
+			Attempts:  delivery.Attempts,
+			LastError: delivery.LastError,
+			Log:       make([]api.WebhookAttempt, len(delivery.AttemptLog)),
+		}
+		switch {
+		case delivery.DeliveredAt.Valid:
+			response[i].DeliveredAt = delivery.DeliveredAt.Time.Format(time.RFC3339)
+		case delivery.FailedAt.Valid:
+			response[i].FailedAt = delivery.FailedAt.Time.Format(time.RFC3339)
+		default:
+			response[i].NextAttemptAt = delivery.NextAttemptAt.Format(time.RFC3339)
+		}
+		for j, attempt := range delivery.AttemptLog {
+			response[i].Log[j] = api.WebhookAttempt{
+				CreatedAt:  attempt.CreatedAt.Format(time.RFC3339),
+				StatusCode: attempt.StatusCode,
+				Error:      attempt.Error,
+				DurationMs: attempt.Duration.Milliseconds(),
+			}
+		}
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+func webhookID(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		_ = ctx.Error(problem.New(http.StatusBadRequest, problem.CodeWebhookInvalidID))
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func knownWebhookEvent(event string) bool {
+	for _, known := range models.WebhookEvents {
+		if string(known) == event {
+			return true
+		}
+	}
+	return false
+}
+
+func webhookSubscriptionResponse(subscription *models.WebhookSubscription) api.WebhookSubscription {
+	response := api.WebhookSubscription{
+		ID:        subscription.ID,
+		CreatedAt: subscription.CreatedAt.Format(time.RFC3339),
+		Partner:   subscription.Partner,
+		URL:       subscription.URL,
+		Events:    []string{},
+	}
+	if subscription.Events != "" {
+		response.Events = strings.Split(subscription.Events, ",")
+	}
+	return response
+}
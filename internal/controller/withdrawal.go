@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ksusonic/gophermart/internal/api"
+	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/problem"
+	"github.com/ksusonic/gophermart/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (c *UserController) withdrawalCancelHandler(ctx *gin.Context) {
+	userID, err := c.auth.GetUserID(ctx)
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+
+	transitionWithdrawal(ctx, c.balance, service.TransitionInput{
+		ActorID: userID,
+		UserID:  userID,
+		From:    []models.WithdrawalStatus{models.WithdrawalStatusPending},
+		To:      models.WithdrawalStatusCancelled,
+	})
+}
+
+// transitionWithdrawal changes status of withdrawal of order from path
+func transitionWithdrawal(ctx *gin.Context, balance BalanceService, input service.TransitionInput) {
+	input.Order = ctx.Param("order")
+	withdrawal, err := balance.TransitionWithdrawal(ctx.Request.Context(), input, client(ctx))
+	if errors.Is(err, models.ErrConflict) {
+		_ = ctx.Error(problem.New(http.StatusConflict, problem.CodeWithdrawalInvalidTransition, input.To))
+		return
+	}
+	if err != nil {
+		_ = ctx.Error(err)
+		return
+	}
+	ctx.JSON(http.StatusOK, api.StatusResponse{Status: string(withdrawal.Status)})
+}
@@ -1,14 +1,33 @@
 package ctxdata
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"go.uber.org/zap"
+)
 
 type ctxKey string
 
+// RequestIDHeader carries request ID in HTTP requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
 const (
-	ctxKeyUserID ctxKey = "user_id"
+	ctxKeyUserID    ctxKey = "user_id"
+	ctxKeyAdmin     ctxKey = "admin"
+	ctxKeyRequestID ctxKey = "request_id"
 )
 
-func GetUserID(ctx *gin.Context) (uint, bool) {
+// Keys stores values of request, *gin.Context is one
+type Keys interface {
+	Set(key string, value any)
+	Get(key string) (value any, exists bool)
+}
+
+func GetUserID(ctx Keys) (uint, bool) {
 	userID, exists := ctx.Get(string(ctxKeyUserID))
 	if exists {
 		return userID.(uint), exists
@@ -16,6 +35,65 @@ func GetUserID(ctx *gin.Context) (uint, bool) {
 	return 0, false
 }
 
-func SetUserID(ctx *gin.Context, userID uint) {
+func SetUserID(ctx Keys, userID uint) {
 	ctx.Set(string(ctxKeyUserID), userID)
 }
+
+// WithUserID returns context carrying user ID, for requests served outside gin
+func WithUserID(ctx context.Context, userID uint) context.Context {
+	return context.WithValue(ctx, ctxKeyUserID, userID)
+}
+
+// UserID returns user ID of context made by WithUserID
+func UserID(ctx context.Context) (uint, bool) {
+	userID, ok := ctx.Value(ctxKeyUserID).(uint)
+	return userID, ok
+}
+
+func IsAdmin(ctx Keys) bool {
+	admin, _ := ctx.Get(string(ctxKeyAdmin))
+	return admin == true
+}
+
+func SetAdmin(ctx Keys, admin bool) {
+	ctx.Set(string(ctxKeyAdmin), admin)
+}
+
+func GetRequestID(ctx Keys) string {
+	requestID, _ := ctx.Get(string(ctxKeyRequestID))
+	s, _ := requestID.(string)
+	return s
+}
+
+func SetRequestID(ctx Keys, requestID string) {
+	ctx.Set(string(ctxKeyRequestID), requestID)
+}
+
+// AcceptRequestID returns request ID sent by client, or a new one if client sent none or a too long one
+func AcceptRequestID(requestID string) string {
+	if requestID != "" && len(requestID) <= maxRequestIDLength {
+		return requestID
+	}
+	bytes := make([]byte, 16)
+	_, _ = rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
+
+// WithRequestID returns context carrying request ID, for requests served outside gin
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, ctxKeyRequestID, requestID)
+}
+
+// RequestID returns request ID of context derived from request context
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(ctxKeyRequestID).(string)
+	return requestID
+}
+
+// Logger returns base logger whose lines carry request ID of ctx, if it has one
+func Logger(ctx context.Context, base *zap.SugaredLogger) *zap.SugaredLogger {
+	if requestID := RequestID(ctx); requestID != "" {
+		return base.With("request_id", requestID)
+	}
+	return base
+}
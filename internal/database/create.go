@@ -1,11 +1,230 @@
 package database
 
-import "github.com/ksusonic/gophermart/internal/models"
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
 
-func (d *DB) CreateUser(user *models.User) error {
-	return d.Orm.Create(user).Error
+	"github.com/ksusonic/gophermart/internal/events"
+	"github.com/ksusonic/gophermart/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (d *DB) CreateUser(ctx context.Context, user *models.User) error {
+	return d.inTx(ctx, func(tx Repos) error {
+		return tx.Users.Create(user)
+	})
+}
+
+func (d *DB) CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	return orm.Create(event).Error
+}
+
+func (d *DB) CreatePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	return orm.Create(token).Error
+}
+
+// ExpirePoints posts expirations of points accrued before cutoff and not spent yet.
+// Spending consumes oldest points first, so for every user it expires
+// accrued before cutoff minus everything withdrawn or expired so far
+func (d *DB) ExpirePoints(ctx context.Context, cutoff time.Time) (int64, error) {
+	var expired int64
+	err := d.inTx(ctx, func(repos Repos) error {
+		tx := repos.orm
+		// one replica at a time
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('point_expirations'))").Error; err != nil {
+			return fmt.Errorf("could not lock expirations: %w", err)
+		}
+		// withdrawals lock user row as well, so balance can't be spent twice
+		err := tx.Exec(`
+			SELECT id FROM users
+			WHERE id IN (SELECT user_id FROM orders WHERE accrued_at < ?)
+			ORDER BY id
+			FOR UPDATE`,
+			cutoff,
+		).Error
+		if err != nil {
+			return fmt.Errorf("could not lock users: %w", err)
+		}
+
+		result := tx.Exec(`
+			INSERT INTO point_expirations (created_at, user_id, amount, cutoff)
+			SELECT @now, a.user_id, a.accrued - coalesce(w.spent, 0) - coalesce(e.expired, 0), @cutoff
+			FROM (
+				SELECT user_id, sum(accrual) AS accrued FROM orders
+				WHERE accrued_at < @cutoff AND deleted_at IS NULL
+				GROUP BY user_id
+			) a
+			LEFT JOIN (
+				SELECT user_id, sum(sum) AS spent FROM withdrawals
+				WHERE status IN @held AND deleted_at IS NULL
+				GROUP BY user_id
+			) w USING (user_id)
+			LEFT JOIN (
+				SELECT user_id, sum(amount) AS expired FROM point_expirations
+				GROUP BY user_id
+			) e USING (user_id)
+			WHERE a.accrued - coalesce(w.spent, 0) - coalesce(e.expired, 0) > 0`,
+			map[string]any{
+				"now":    time.Now(),
+				"cutoff": cutoff,
+				"held":   models.WithdrawalStatusesHeld,
+			},
+		)
+		expired = result.RowsAffected
+		return result.Error
+	})
+	return expired, err
+}
+
+// ClaimOrder uploads order, see OrderRepository.Claim
+func (d *DB) ClaimOrder(ctx context.Context, order *models.Order) (*models.OrderClaim, error) {
+	var claim *models.OrderClaim
+	err := d.inTx(ctx, func(tx Repos) (err error) {
+		claim, err = tx.Orders.Claim(order, time.Now())
+		return err
+	})
+	return claim, err
+}
+
+// ClaimOrders claims orders in a single transaction, claims are in order of orders
+func (d *DB) ClaimOrders(ctx context.Context, orders []models.Order) ([]models.OrderClaim, error) {
+	claims := make([]models.OrderClaim, len(orders))
+	now := time.Now()
+	err := d.inTx(ctx, func(tx Repos) error {
+		for i := range orders {
+			claim, err := tx.Orders.Claim(&orders[i], now)
+			if err != nil {
+				return fmt.Errorf("could not claim order %s: %w", orders[i].ID, err)
+			}
+			claims[i] = *claim
+		}
+		return nil
+	})
+	return claims, err
+}
+
+// CreateIdempotencyKey returns false if key of user already exists
+func (d *DB) CreateIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) (bool, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	tx := orm.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+	return tx.RowsAffected == 1, tx.Error
+}
+
+// AdjustAccrual changes accrual of processed order and posts adjustment with resulting balance.
+// Returns models.ConflictError if order accrual was changed concurrently
+func (d *DB) AdjustAccrual(ctx context.Context, order *models.Order, accrual int64, status models.OrderStatus) (*models.AccrualAdjustment, error) {
+	var adjustment *models.AccrualAdjustment
+	err := d.inTx(ctx, func(tx Repos) error {
+		adjustment = &models.AccrualAdjustment{
+			OrderID: order.ID,
+			UserID:  order.UserID,
+			Before:  order.Accrual.Int64,
+			After:   accrual,
+			Delta:   accrual - order.Accrual.Int64,
+		}
+		// same lock as withdrawals, so balance after adjustment is exact
+		if err := tx.Users.Lock(order.UserID); err != nil {
+			return fmt.Errorf("could not lock user: %w", err)
+		}
+		if err := tx.Orders.SetAccrual(order, accrual, status); err != nil {
+			return err
+		}
+
+		stats, err := tx.Users.Stats(order.UserID)
+		if err != nil {
+			return fmt.Errorf("could not calculate balance: %w", err)
+		}
+		if adjustment.Delta < 0 {
+			offset, err := offsetExpirations(tx, order, stats.Expired)
+			if err != nil {
+				return fmt.Errorf("could not offset expirations: %w", err)
+			}
+			stats.Expired -= offset
+			stats.Current += offset
+		}
+		adjustment.BalanceAfter = stats.Current
+		adjustment.Flagged = stats.Current < 0
+
+		if err := tx.orm.Create(adjustment).Error; err != nil {
+			return err
+		}
+		return tx.Publish(events.OrderAdjusted{
+			Number:       adjustment.OrderID,
+			UserID:       adjustment.UserID,
+			Status:       status,
+			Before:       adjustment.Before,
+			After:        adjustment.After,
+			BalanceAfter: adjustment.BalanceAfter,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	order.Accrual.Int64 = accrual
+	order.Status = status
+	return adjustment, nil
+}
+
+// offsetExpirations gives back expired points lowered accrual of order no longer backs,
+// otherwise points taken by accrual adjustment would be taken by their expiration as well.
+// It returns points given back
+func offsetExpirations(tx Repos, order *models.Order, expired int64) (int64, error) {
+	runs, err := tx.Users.ExpirationRuns(order.UserID)
+	if err != nil {
+		return 0, err
+	}
+	excess := models.ExpirationExcess(expired, runs)
+	if excess == 0 {
+		return 0, nil
+	}
+	return excess, tx.orm.Create(&models.PointExpiration{
+		UserID:  order.UserID,
+		OrderID: sql.NullString{String: order.ID, Valid: true},
+		Amount:  -excess,
+		Cutoff:  runs[len(runs)-1].Cutoff,
+	}).Error
+}
+
+func (d *DB) CreateWebhookSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	return orm.Create(subscription).Error
+}
+
+// EnqueueWebhook writes delivery of event to every subscription interested in it,
+// event already enqueued for a subscription is skipped
+func (d *DB) EnqueueWebhook(ctx context.Context, eventID uint, event models.WebhookEvent, payload []byte) error {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	return orm.Exec(`
+		INSERT INTO webhook_deliveries (created_at, subscription_id, event_id, event, payload, attempts, next_attempt_at)
+		SELECT @now, id, @event_id, @event, @payload, 0, @now
+		FROM webhook_subscriptions
+		WHERE deleted_at IS NULL AND (events = '' OR @event = ANY(string_to_array(events, ',')))
+		ON CONFLICT (subscription_id, event_id) DO NOTHING`,
+		map[string]any{
+			"now":      time.Now(),
+			"event_id": eventID,
+			"event":    string(event),
+			"payload":  payload,
+		},
+	).Error
 }
 
-func (d *DB) CreateOrder(order *models.Order) error {
-	return d.Orm.Create(order).Error
+// publish writes event to outbox within transaction of the change
+func publish(tx *gorm.DB, event events.Event) error {
+	row, err := events.Encode(event, time.Now())
+	if err != nil {
+		return err
+	}
+	return tx.Create(row).Error
 }
@@ -1,20 +1,24 @@
 package database
 
 import (
+	"errors"
 	"fmt"
+
 	"github.com/ksusonic/gophermart/internal/models"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 type DB struct {
-	Orm *gorm.DB
+	orm *gorm.DB
+	cfg Config
 }
 
-func NewDB(dbConnect string, logger *zap.SugaredLogger) (*DB, error) {
-	db, err := gorm.Open(postgres.Open(dbConnect), &gorm.Config{})
+func NewDB(dbConnect string, cfg Config, logger *zap.SugaredLogger) (*DB, error) {
+	db, err := gorm.Open(postgres.Open(dbConnect), &gorm.Config{Logger: newGormLogger(logger.Named("gorm"))})
 	if err != nil {
 		logger.Panic(err)
 	}
@@ -25,7 +29,105 @@ func NewDB(dbConnect string, logger *zap.SugaredLogger) (*DB, error) {
 	if err := db.AutoMigrate(&models.Order{}); err != nil {
 		return nil, fmt.Errorf("could not migrate Order: %v", err)
 	}
+	if err := db.AutoMigrate(&models.Withdrawal{}); err != nil {
+		return nil, fmt.Errorf("could not migrate Withdrawal: %v", err)
+	}
+	if err := migrateWithdrawals(db); err != nil {
+		return nil, fmt.Errorf("could not move withdrawals out of orders: %v", err)
+	}
+	if err := db.AutoMigrate(&models.PointExpiration{}); err != nil {
+		return nil, fmt.Errorf("could not migrate PointExpiration: %v", err)
+	}
+	if err := db.AutoMigrate(&models.AccrualAdjustment{}); err != nil {
+		return nil, fmt.Errorf("could not migrate AccrualAdjustment: %v", err)
+	}
+	// orders processed before accrued_at appeared
+	err = db.Exec(
+		"UPDATE orders SET accrued_at = updated_at WHERE accrual IS NOT NULL AND accrued_at IS NULL",
+	).Error
+	if err != nil {
+		return nil, fmt.Errorf("could not fill accrued_at: %v", err)
+	}
+	if err := db.AutoMigrate(&models.AuditEvent{}); err != nil {
+		return nil, fmt.Errorf("could not migrate AuditEvent: %v", err)
+	}
+	if err := db.Exec(auditAppendOnlyTrigger).Error; err != nil {
+		return nil, fmt.Errorf("could not create audit trigger: %v", err)
+	}
+	if err := db.AutoMigrate(&models.LoginAttempt{}); err != nil {
+		return nil, fmt.Errorf("could not migrate LoginAttempt: %v", err)
+	}
+	if err := db.AutoMigrate(&models.RateLimitBucket{}); err != nil {
+		return nil, fmt.Errorf("could not migrate RateLimitBucket: %v", err)
+	}
+	// buckets taken before full_at appeared
+	err = db.Exec("UPDATE rate_limit_buckets SET full_at = refilled_at WHERE full_at IS NULL").Error
+	if err != nil {
+		return nil, fmt.Errorf("could not fill full_at: %v", err)
+	}
+	if err := db.AutoMigrate(&models.PasswordResetToken{}); err != nil {
+		return nil, fmt.Errorf("could not migrate PasswordResetToken: %v", err)
+	}
+	if err := db.AutoMigrate(&models.IdempotencyKey{}); err != nil {
+		return nil, fmt.Errorf("could not migrate IdempotencyKey: %v", err)
+	}
+	if err := db.AutoMigrate(&models.WebhookSubscription{}, &models.WebhookDelivery{}, &models.WebhookAttempt{}); err != nil {
+		return nil, fmt.Errorf("could not migrate webhooks: %v", err)
+	}
+	if err := db.AutoMigrate(&models.OutboxEvent{}); err != nil {
+		return nil, fmt.Errorf("could not migrate OutboxEvent: %v", err)
+	}
 	logger.Debug("successfully migrated")
 
-	return &DB{Orm: db}, nil
+	return &DB{orm: db, cfg: cfg}, nil
+}
+
+// migrateWithdrawals moves withdrawals stored as orders with withdraw column to their own table
+func migrateWithdrawals(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.Order{}, "withdraw") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`
+			INSERT INTO withdrawals (created_at, updated_at, order_id, user_id, sum)
+			SELECT created_at, updated_at, id, user_id, withdraw FROM orders
+			WHERE withdraw IS NOT NULL
+			ON CONFLICT (order_id) DO NOTHING`,
+		).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM orders WHERE withdraw IS NOT NULL").Error; err != nil {
+			return err
+		}
+		return tx.Migrator().DropColumn(&models.Order{}, "withdraw")
+	})
+}
+
+// auditAppendOnlyTrigger forbids UPDATE and DELETE on audit_events
+const auditAppendOnlyTrigger = `
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only
+	BEFORE UPDATE OR DELETE ON audit_events
+	FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+`
+
+func paginate(tx *gorm.DB, page models.Page) *gorm.DB {
+	if page.Limit > 0 {
+		tx = tx.Limit(page.Limit)
+	}
+	return tx.Offset(page.Offset)
+}
+
+const pgUniqueViolation = "23505"
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation
 }
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/models"
+)
+
+func (d *DB) ResetLoginAttempts(ctx context.Context, key string) error {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	return orm.Where("key = ?", key).Delete(&models.LoginAttempt{}).Error
+}
+
+func (d *DB) DeleteIdempotencyKey(ctx context.Context, userID uint, key string) error {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	return orm.Where("user_id = ? and key = ?", userID, key).Delete(&models.IdempotencyKey{}).Error
+}
+
+// DeleteExpiredIdempotencyKeys drops completed keys created before completedBefore
+// and keys in progress created before startedBefore, those would be taken over anyway
+func (d *DB) DeleteExpiredIdempotencyKeys(ctx context.Context, completedBefore, startedBefore time.Time) (int64, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	tx := orm.Where(
+		"(status_code <> 0 and created_at < ?) or (status_code = 0 and created_at < ?)",
+		completedBefore, startedBefore,
+	).Delete(&models.IdempotencyKey{})
+	return tx.RowsAffected, tx.Error
+}
+
+// DeleteWebhookSubscription stops enqueueing events for subscription, pending deliveries are dropped by sender
+func (d *DB) DeleteWebhookSubscription(ctx context.Context, id uint) error {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	tx := orm.Delete(&models.WebhookSubscription{}, id)
+	if tx.Error == nil && tx.RowsAffected == 0 {
+		return &models.NotFoundError{Entity: "webhook subscription"}
+	}
+	return tx.Error
+}
+
+// DeleteIdleRateLimitBuckets drops buckets refilled before now, they are recreated full on next request
+func (d *DB) DeleteIdleRateLimitBuckets(ctx context.Context, now time.Time) (int64, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	tx := orm.Where("full_at < ?", now).Delete(&models.RateLimitBucket{})
+	return tx.RowsAffected, tx.Error
+}
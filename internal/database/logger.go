@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/ctxdata"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+const slowQueryThreshold = 200 * time.Millisecond
+
+// gormLogger writes gorm logs to zap, queries are logged with request ID of their context
+type gormLogger struct {
+	logger *zap.SugaredLogger
+	level  gormlogger.LogLevel
+}
+
+func newGormLogger(logger *zap.SugaredLogger) *gormLogger {
+	level := gormlogger.Warn
+	if logger.Desugar().Core().Enabled(zapcore.DebugLevel) {
+		level = gormlogger.Info
+	}
+	return &gormLogger{logger: logger, level: level}
+}
+
+func (l *gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	clone := *l
+	clone.level = level
+	return &clone
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, args ...any) {
+	if l.level >= gormlogger.Info {
+		ctxdata.Logger(ctx, l.logger).Infof(msg, args...)
+	}
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, args ...any) {
+	if l.level >= gormlogger.Warn {
+		ctxdata.Logger(ctx, l.logger).Warnf(msg, args...)
+	}
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, args ...any) {
+	if l.level >= gormlogger.Error {
+		ctxdata.Logger(ctx, l.logger).Errorf(msg, args...)
+	}
+}
+
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && l.level >= gormlogger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		ctxdata.Logger(ctx, l.logger).Errorw("query failed", "error", err, "sql", sql, "rows", rows, "elapsed", elapsed)
+	case elapsed > slowQueryThreshold && l.level >= gormlogger.Warn:
+		sql, rows := fc()
+		ctxdata.Logger(ctx, l.logger).Warnw("slow query", "sql", sql, "rows", rows, "elapsed", elapsed)
+	case l.level >= gormlogger.Info:
+		sql, rows := fc()
+		ctxdata.Logger(ctx, l.logger).Debugw("query", "sql", sql, "rows", rows, "elapsed", elapsed)
+	}
+}
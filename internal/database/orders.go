@@ -0,0 +1,96 @@
+package database
+
+import (
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/events"
+	"github.com/ksusonic/gophermart/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type OrderRepository struct {
+	orm *gorm.DB
+}
+
+// Claim inserts order unless it exists and returns its owner in one statement,
+// so concurrent uploads of the same number get deterministic results
+func (r OrderRepository) Claim(order *models.Order, now time.Time) (*models.OrderClaim, error) {
+	claim := &models.OrderClaim{}
+	err := r.orm.Raw(`
+		INSERT INTO orders (id, created_at, updated_at, user_id, status)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET id = EXCLUDED.id
+		RETURNING user_id AS owner_id, (xmax = 0) AS created`,
+		order.ID, now, now, order.UserID, order.Status,
+	).Scan(claim).Error
+	if err != nil || !claim.Created {
+		return claim, err
+	}
+	return claim, publish(r.orm, events.OrderUploaded{Number: order.ID, UserID: order.UserID})
+}
+
+// ListByUser returns orders of user, oldest upload first
+func (r OrderRepository) ListByUser(userID uint, page models.Page) (*[]models.Order, error) {
+	orders := &[]models.Order{}
+	err := paginate(r.orm.Model(&models.Order{}), page).
+		Where("user_id = ?", userID).
+		Order("created_at, id").
+		Find(orders).
+		Error
+	return orders, err
+}
+
+func (r OrderRepository) WithStatus(status ...models.OrderStatus) (*[]models.Order, error) {
+	orders := &[]models.Order{}
+	err := r.orm.Model(&models.Order{}).Where("status in ?", status).Find(orders).Error
+	return orders, err
+}
+
+// Accrued returns orders of user with accrual, oldest accrual first
+func (r OrderRepository) Accrued(userID uint) (*[]models.Order, error) {
+	orders := &[]models.Order{}
+	err := r.orm.Model(&models.Order{}).
+		Where("user_id = ? and accrual is not null and accrued_at is not null", userID).
+		Order("accrued_at").
+		Find(orders).
+		Error
+	return orders, err
+}
+
+// AccruedSince returns processed orders with accrual posted after since
+func (r OrderRepository) AccruedSince(since time.Time) (*[]models.Order, error) {
+	orders := &[]models.Order{}
+	err := r.orm.Model(&models.Order{}).
+		Where("status = ? and accrual is not null and accrued_at >= ?", models.OrderStatusProcessed, since).
+		Find(orders).
+		Error
+	return orders, err
+}
+
+// Update saves order and publishes its final status
+func (r OrderRepository) Update(order *models.Order) error {
+	if err := r.orm.Save(order).Error; err != nil {
+		return err
+	}
+	switch order.Status {
+	case models.OrderStatusProcessed:
+		return publish(r.orm, events.OrderAccrued{Number: order.ID, UserID: order.UserID, Accrual: order.Accrual.Int64})
+	case models.OrderStatusInvalid:
+		return publish(r.orm, events.OrderRejected{Number: order.ID, UserID: order.UserID})
+	}
+	return nil
+}
+
+// SetAccrual changes accrual of order unless it was changed since order was read,
+// returns models.ConflictError then
+func (r OrderRepository) SetAccrual(order *models.Order, accrual int64, status models.OrderStatus) error {
+	tx := r.orm.Model(&models.Order{}).
+		Where("id = ? AND accrual = ?", order.ID, order.Accrual.Int64).
+		Updates(map[string]any{"accrual": accrual, "status": status})
+	err := tx.Error
+	if err == nil && tx.RowsAffected == 0 {
+		err = &models.ConflictError{Entity: "order", Reason: "order accrual was changed concurrently"}
+	}
+	return err
+}
@@ -1,69 +1,167 @@
 package database
 
 import (
-	"database/sql"
-	"github.com/ksusonic/gophermart/internal/api"
+	"context"
+	"time"
+
 	"github.com/ksusonic/gophermart/internal/models"
+
+	"gorm.io/gorm"
 )
 
-func (d *DB) GetUserByLogin(login string) (*models.User, error) {
-	user := &models.User{}
-	tx := d.Orm.Where("login = ?", login).Limit(1).Find(user)
-	err := tx.Error
-	if err == nil && tx.RowsAffected == 0 {
-		err = sql.ErrNoRows
+func (d *DB) GetUserByLogin(ctx context.Context, login string) (*models.User, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	return newRepos(orm).Users.GetByLogin(login)
+}
+
+func (d *DB) GetUserByID(ctx context.Context, id uint) (*models.User, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	return newRepos(orm).Users.GetByID(id)
+}
+
+func (d *DB) GetWithdrawalsByUserID(ctx context.Context, userID uint, page models.Page) (*[]models.Withdrawal, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	return newRepos(orm).Withdrawals.ListByUser(userID, page)
+}
+
+func (d *DB) GetOrdersByUserID(ctx context.Context, userID uint, page models.Page) (*[]models.Order, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	return newRepos(orm).Orders.ListByUser(userID, page)
+}
+
+func (d *DB) CalculateUserStats(ctx context.Context, userID uint) (*models.Balance, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	return newRepos(orm).Users.Stats(userID)
+}
+
+func (d *DB) GetOrdersWithStatus(ctx context.Context, status ...models.OrderStatus) (*[]models.Order, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	return newRepos(orm).Orders.WithStatus(status...)
+}
+
+func (d *DB) GetAuditEvents(ctx context.Context, filter models.AuditEventFilter) (*[]models.AuditEvent, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	events := &[]models.AuditEvent{}
+	tx := orm.Model(&models.AuditEvent{})
+	if filter.ActorID.Valid {
+		tx = tx.Where("actor_id = ?", filter.ActorID.Int64)
+	}
+	if filter.Action != "" {
+		tx = tx.Where("action = ?", filter.Action)
 	}
-	return user, err
+	if filter.Target != "" {
+		tx = tx.Where("target = ?", filter.Target)
+	}
+	if !filter.From.IsZero() {
+		tx = tx.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		tx = tx.Where("created_at < ?", filter.To)
+	}
+	err := tx.Order("id desc").Limit(filter.Limit).Offset(filter.Offset).Find(events).Error
+	return events, err
 }
 
-func (d *DB) GetOrderByID(id string) (*models.Order, error) {
-	order := &models.Order{}
-	tx := d.Orm.Model(&models.Order{}).Where("id = ?", id).Limit(1).Find(order)
-	err := tx.Error
-	if err == nil && tx.RowsAffected == 0 {
-		err = sql.ErrNoRows
+// GetPasswordResetToken returns unused and unexpired token by its hash
+func (d *DB) GetPasswordResetToken(ctx context.Context, tokenHash string, now time.Time) (*models.PasswordResetToken, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	token := &models.PasswordResetToken{}
+	tx := orm.
+		Where("token_hash = ? and used_at is null and expires_at > ?", tokenHash, now).
+		Limit(1).
+		Find(token)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return nil, &models.NotFoundError{Entity: "password reset token"}
 	}
-	return order, err
+	return token, nil
 }
 
-func (d *DB) GetWithdrawnOrdersByUserID(userID uint) (*[]models.Order, error) {
-	withdrawals := &[]models.Order{}
-	tx := d.Orm.Model(&models.Order{}).Where("user_id = ? and withdraw is not null", userID).Find(withdrawals)
-	err := tx.Error
-	if err == nil && tx.RowsAffected == 0 {
-		err = sql.ErrNoRows
+func (d *DB) GetIdempotencyKey(ctx context.Context, userID uint, key string) (*models.IdempotencyKey, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	idempotencyKey := &models.IdempotencyKey{}
+	tx := orm.Where("user_id = ? and key = ?", userID, key).Limit(1).Find(idempotencyKey)
+	if tx.Error != nil {
+		return nil, tx.Error
 	}
-	return withdrawals, err
+	if tx.RowsAffected == 0 {
+		return nil, &models.NotFoundError{Entity: "idempotency key"}
+	}
+	return idempotencyKey, nil
+}
+
+// GetAccruedOrders returns orders of user with accrual, oldest accrual first
+func (d *DB) GetAccruedOrders(ctx context.Context, userID uint) (*[]models.Order, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	return newRepos(orm).Orders.Accrued(userID)
 }
 
-func (d *DB) GetOrdersByUserID(userID uint) (*[]models.Order, error) {
-	orders := &[]models.Order{}
-	tx := d.Orm.Model(&models.Order{}).Where("user_id = ?", userID).Find(orders)
-	err := tx.Error
-	if err == nil && tx.RowsAffected == 0 {
-		err = sql.ErrNoRows
+// GetOrdersAccruedSince returns processed orders with accrual posted after since
+func (d *DB) GetOrdersAccruedSince(ctx context.Context, since time.Time) (*[]models.Order, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	return newRepos(orm).Orders.AccruedSince(since)
+}
+
+// GetAccrualAdjustments returns adjustments, newest first
+func (d *DB) GetAccrualAdjustments(ctx context.Context, flaggedOnly bool) (*[]models.AccrualAdjustment, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	adjustments := &[]models.AccrualAdjustment{}
+	query := orm.Model(&models.AccrualAdjustment{})
+	if flaggedOnly {
+		query = query.Where("flagged")
 	}
-	return orders, err
+	err := query.Order("id desc").Find(adjustments).Error
+	return adjustments, err
 }
 
-func (d *DB) CalculateUserStats(userID uint) (*api.UserInfo, error) {
-	userInfo := &api.UserInfo{}
-	err := d.Orm.
-		Table("orders").
-		Select("sum(accrual) as balance, sum(withdraw) as withdraw").
-		Where("user_id = ?", userID).
-		Scan(userInfo).
-		Error
-	userInfo.Balance -= userInfo.Withdraw
-	return userInfo, err
+func (d *DB) GetWebhookSubscriptions(ctx context.Context) (*[]models.WebhookSubscription, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	subscriptions := &[]models.WebhookSubscription{}
+	err := orm.Order("id").Find(subscriptions).Error
+	return subscriptions, err
 }
 
-func (d *DB) GetOrdersWithStatus(status ...models.OrderStatus) (*[]models.Order, error) {
-	orders := &[]models.Order{}
-	tx := d.Orm.Model(&models.Order{}).Where("status in ?", status).Find(orders)
-	err := tx.Error
-	if err == nil && tx.RowsAffected == 0 {
-		err = sql.ErrNoRows
+func (d *DB) GetWebhookSubscriptionByID(ctx context.Context, id uint) (*models.WebhookSubscription, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	subscription := &models.WebhookSubscription{}
+	tx := orm.Where("id = ?", id).Limit(1).Find(subscription)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return nil, &models.NotFoundError{Entity: "webhook subscription"}
 	}
-	return orders, err
+	return subscription, nil
+}
+
+// GetWebhookDeliveries returns latest deliveries of subscription with their attempt log
+func (d *DB) GetWebhookDeliveries(ctx context.Context, subscriptionID uint, limit int) (*[]models.WebhookDelivery, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	deliveries := &[]models.WebhookDelivery{}
+	err := orm.Preload("AttemptLog", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("id")
+	}).
+		Where("subscription_id = ?", subscriptionID).
+		Order("id desc").
+		Limit(limit).
+		Find(deliveries).
+		Error
+	return deliveries, err
 }
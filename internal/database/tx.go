@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/events"
+	"github.com/ksusonic/gophermart/internal/service"
+	"github.com/ksusonic/gophermart/internal/utils"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+const (
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+
+	retryBackoff    = 10 * time.Millisecond
+	retryMaxBackoff = 500 * time.Millisecond
+)
+
+type Config struct {
+	Isolation  sql.IsolationLevel
+	MaxRetries int           // of transactions failed to serialize or deadlocked
+	Timeout    time.Duration // of every method call including retries, zero means no limit
+}
+
+// ParseIsolation accepts isolation level as in SQL, spaces may be replaced by underscores
+func ParseIsolation(name string) (sql.IsolationLevel, error) {
+	switch strings.ToLower(strings.ReplaceAll(name, "_", " ")) {
+	case "", "default":
+		return sql.LevelDefault, nil
+	case "read committed":
+		return sql.LevelReadCommitted, nil
+	case "repeatable read":
+		return sql.LevelRepeatableRead, nil
+	case "serializable":
+		return sql.LevelSerializable, nil
+	}
+	return sql.LevelDefault, fmt.Errorf("unknown isolation level %q", name)
+}
+
+// Repos are repositories bound to one transaction
+type Repos struct {
+	Users       UserRepository
+	Orders      OrderRepository
+	Withdrawals WithdrawalRepository
+
+	orm *gorm.DB
+}
+
+func newRepos(orm *gorm.DB) Repos {
+	return Repos{
+		Users:       UserRepository{orm: orm},
+		Orders:      OrderRepository{orm: orm},
+		Withdrawals: WithdrawalRepository{orm: orm},
+		orm:         orm,
+	}
+}
+
+// Publish writes event to outbox, it is dispatched only if transaction commits
+func (r Repos) Publish(event events.Event) error {
+	return publish(r.orm, event)
+}
+
+// serviceTx gives repositories of transaction to services
+type serviceTx struct {
+	repos Repos
+}
+
+func (t serviceTx) Users() service.UserRepository {
+	return t.repos.Users
+}
+
+func (t serviceTx) Withdrawals() service.WithdrawalRepository {
+	return t.repos.Withdrawals
+}
+
+// InTx runs fn of service in a transaction, see inTx
+func (d *DB) InTx(ctx context.Context, fn func(tx service.Tx) error) error {
+	return d.inTx(ctx, func(repos Repos) error {
+		return fn(serviceTx{repos: repos})
+	})
+}
+
+// inTx runs fn in a transaction, committed if fn returns nil.
+// Transactions failed to serialize or deadlocked are retried from scratch, so fn may be called again
+// and must not leak state of failed attempts
+func (d *DB) inTx(ctx context.Context, fn func(tx Repos) error) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	options := &sql.TxOptions{Isolation: d.cfg.Isolation}
+	for attempt := 1; ; attempt++ {
+		err := d.orm.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return fn(newRepos(tx))
+		}, options)
+		if err == nil || !isRetryable(err) || attempt > d.cfg.MaxRetries {
+			return err
+		}
+
+		select {
+		case <-time.After(utils.Backoff(retryBackoff, retryMaxBackoff, attempt)):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// session returns orm bound to ctx limited by timeout, statements of it are not in a transaction
+func (d *DB) session(ctx context.Context) (*gorm.DB, context.CancelFunc) {
+	ctx, cancel := d.withTimeout(ctx)
+	return d.orm.WithContext(ctx), cancel
+}
+
+func (d *DB) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.cfg.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d.cfg.Timeout)
+}
+
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == pgSerializationFailure || pgErr.Code == pgDeadlockDetected)
+}
@@ -1,7 +1,257 @@
 package database
 
-import "github.com/ksusonic/gophermart/internal/models"
+import (
+	"context"
+	"sort"
+	"time"
 
-func (d *DB) UpdateOrder(order *models.Order) error {
-	return d.Orm.Save(order).Error
+	"github.com/ksusonic/gophermart/internal/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// UpdateOrder saves order and publishes its final status
+func (d *DB) UpdateOrder(ctx context.Context, order *models.Order) error {
+	return d.inTx(ctx, func(tx Repos) error {
+		return tx.Orders.Update(order)
+	})
+}
+
+func (d *DB) UpdateUserPasswordHash(ctx context.Context, userID uint, hash string) error {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	return newRepos(orm).Users.UpdatePasswordHash(userID, hash)
+}
+
+// ChangeUserPassword sets password hash and revokes all issued tokens of user
+func (d *DB) ChangeUserPassword(ctx context.Context, userID uint, hash string) (*models.User, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	return newRepos(orm).Users.ChangePassword(userID, hash)
+}
+
+// GrantAdmin makes users with logins admins, returning only users who were not admins before
+func (d *DB) GrantAdmin(ctx context.Context, logins []string) (*[]models.User, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	users := &[]models.User{}
+	err := orm.Model(users).
+		Clauses(clause.Returning{}).
+		Where("login IN ? AND NOT admin", logins).
+		Update("admin", true).
+		Error
+	return users, err
+}
+
+// ResetUserPassword consumes reset token and changes password of its user atomically
+func (d *DB) ResetUserPassword(ctx context.Context, tokenHash string, hash string, now time.Time) (*models.User, error) {
+	var user *models.User
+	err := d.inTx(ctx, func(tx Repos) error {
+		token := &models.PasswordResetToken{}
+		result := tx.orm.Model(token).
+			Clauses(clause.Returning{}).
+			Where("token_hash = ? and used_at is null and expires_at > ?", tokenHash, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return &models.NotFoundError{Entity: "password reset token"}
+		}
+
+		var err error
+		user, err = tx.Users.ChangePassword(token.UserID, hash)
+		return err
+	})
+	return user, err
+}
+
+// ReserveLoginAttempt counts attempt of key as failed before credentials are checked, so concurrent
+// attempts can't exceed the limit. The counter restarts if the previous failure is older than window,
+// and the key is locked as soon as a reserved attempt reaches the limit.
+// Locked keys are not counted, reserved is false for them and attempt holds the lock
+func (d *DB) ReserveLoginAttempt(
+	ctx context.Context,
+	key string,
+	now time.Time,
+	limit models.LoginLimit,
+) (attempt *models.LoginAttempt, reserved bool, err error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+
+	const failures = `CASE
+		WHEN login_attempts.last_failure_at < @since THEN 1
+		ELSE login_attempts.failures + 1
+	END`
+	lockedUntil := func(failures string) string {
+		return `CASE WHEN ` + failures + ` >= @max THEN CAST(@now AS timestamptz) +
+			least(@lockout * power(2, ` + failures + ` - @max), @max_lockout) * interval '1 microsecond'
+		END`
+	}
+
+	attempt = &models.LoginAttempt{}
+	tx := orm.Raw(`
+		INSERT INTO login_attempts (key, failures, last_failure_at, locked_until)
+		VALUES (@key, 1, @now, `+lockedUntil("1")+`)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = `+failures+`,
+			last_failure_at = EXCLUDED.last_failure_at,
+			locked_until = `+lockedUntil(failures)+`
+		WHERE login_attempts.locked_until IS NULL OR login_attempts.locked_until <= @now
+		RETURNING *`,
+		map[string]any{
+			"key":         key,
+			"now":         now,
+			"since":       now.Add(-limit.Window),
+			"max":         limit.MaxFailures,
+			"lockout":     limit.Lockout.Microseconds(),
+			"max_lockout": limit.MaxLockout.Microseconds(),
+		},
+	).Scan(attempt)
+	if tx.Error != nil {
+		return nil, false, tx.Error
+	}
+	if tx.RowsAffected > 0 {
+		return attempt, true, nil
+	}
+
+	err = orm.Where("key = ?", key).Take(attempt).Error
+	return attempt, false, err
+}
+
+// ReleaseLoginAttempt uncounts reserved attempt of key, lifting the lock if the attempt set it
+func (d *DB) ReleaseLoginAttempt(ctx context.Context, attempt *models.LoginAttempt) error {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	return orm.Exec(`
+		UPDATE login_attempts SET
+			failures = greatest(failures - 1, 0),
+			locked_until = CASE WHEN locked_until = @locked_until THEN NULL ELSE locked_until END
+		WHERE key = @key`,
+		map[string]any{
+			"key":          attempt.Key,
+			"locked_until": attempt.LockedUntil,
+		},
+	).Error
+}
+
+// UpdateRateLimitBucket calls update with bucket of key locked for the transaction.
+// New buckets have zero RefilledAt
+func (d *DB) UpdateRateLimitBucket(ctx context.Context, key string, update func(bucket *models.RateLimitBucket) error) error {
+	return d.inTx(ctx, func(repos Repos) error {
+		tx := repos.orm
+		bucket := &models.RateLimitBucket{Key: key}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(bucket).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).Take(bucket).Error; err != nil {
+			return err
+		}
+		if err := update(bucket); err != nil {
+			return err
+		}
+		return tx.Save(bucket).Error
+	})
+}
+
+func (d *DB) CompleteIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	return orm.Model(&models.IdempotencyKey{}).
+		Where("user_id = ? and key = ?", key.UserID, key.Key).
+		Updates(map[string]any{
+			"status_code":  key.StatusCode,
+			"content_type": key.ContentType,
+			"headers":      key.Headers,
+			"response":     key.Response,
+		}).Error
+}
+
+func (d *DB) TransitionWithdrawal(
+	ctx context.Context,
+	orderID string,
+	userID uint,
+	from []models.WithdrawalStatus,
+	to models.WithdrawalStatus,
+) (*models.Withdrawal, error) {
+	var withdrawal *models.Withdrawal
+	err := d.inTx(ctx, func(tx Repos) (err error) {
+		withdrawal, err = tx.Withdrawals.Transition(orderID, userID, from, to)
+		return err
+	})
+	return withdrawal, err
+}
+
+// ClaimWebhookDeliveries returns due deliveries and postpones them by lease,
+// so other replicas don't send them while this one does
+func (d *DB) ClaimWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) (*[]models.WebhookDelivery, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	deliveries := &[]models.WebhookDelivery{}
+	err := orm.Raw(`
+		UPDATE webhook_deliveries SET next_attempt_at = @lease
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE delivered_at IS NULL AND failed_at IS NULL AND next_attempt_at <= @now
+			ORDER BY next_attempt_at
+			LIMIT @limit
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		map[string]any{
+			"now":   now,
+			"lease": now.Add(lease),
+			"limit": limit,
+		},
+	).Scan(deliveries).Error
+	return deliveries, err
+}
+
+// RecordWebhookAttempt appends attempt to delivery log and stores delivery state
+func (d *DB) RecordWebhookAttempt(ctx context.Context, delivery *models.WebhookDelivery, attempt *models.WebhookAttempt) error {
+	return d.inTx(ctx, func(repos Repos) error {
+		tx := repos.orm
+		if err := tx.Create(attempt).Error; err != nil {
+			return err
+		}
+		return tx.Model(delivery).
+			Select("attempts", "next_attempt_at", "delivered_at", "failed_at", "last_error").
+			Updates(delivery).
+			Error
+	})
+}
+
+// ClaimOutboxEvents returns due events in order they were published and postpones them by lease,
+// so other replicas don't dispatch them while this one does
+func (d *DB) ClaimOutboxEvents(ctx context.Context, now time.Time, lease time.Duration, limit int) (*[]models.OutboxEvent, error) {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	rows := &[]models.OutboxEvent{}
+	err := orm.Raw(`
+		UPDATE outbox_events SET next_dispatch_at = @lease
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE dispatched_at IS NULL AND failed_at IS NULL AND next_dispatch_at <= @now
+			ORDER BY id
+			LIMIT @limit
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		map[string]any{
+			"now":   now,
+			"lease": now.Add(lease),
+			"limit": limit,
+		},
+	).Scan(rows).Error
+	sort.Slice(*rows, func(i, j int) bool { return (*rows)[i].ID < (*rows)[j].ID })
+	return rows, err
+}
+
+func (d *DB) CompleteOutboxEvent(ctx context.Context, event *models.OutboxEvent) error {
+	orm, cancel := d.session(ctx)
+	defer cancel()
+	return orm.Model(event).
+		Select("attempts", "next_dispatch_at", "dispatched_at", "failed_at", "last_error").
+		Updates(event).
+		Error
 }
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"github.com/ksusonic/gophermart/internal/events"
+	"github.com/ksusonic/gophermart/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UserRepository struct {
+	orm *gorm.DB
+}
+
+func (r UserRepository) Create(user *models.User) error {
+	err := r.orm.Create(user).Error
+	if isUniqueViolation(err) {
+		return &models.ConflictError{Entity: "user", Reason: "user already exists"}
+	}
+	if err != nil {
+		return err
+	}
+	return publish(r.orm, events.UserRegistered{UserID: user.ID, Login: user.Login})
+}
+
+func (r UserRepository) GetByLogin(login string) (*models.User, error) {
+	user := &models.User{}
+	tx := r.orm.Where("login = ?", login).Limit(1).Find(user)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return nil, &models.NotFoundError{Entity: "user"}
+	}
+	return user, nil
+}
+
+func (r UserRepository) GetByID(id uint) (*models.User, error) {
+	user := &models.User{}
+	tx := r.orm.Where("id = ?", id).Limit(1).Find(user)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return nil, &models.NotFoundError{Entity: "user"}
+	}
+	return user, nil
+}
+
+// Lock locks user row until end of transaction.
+// Everything changing balance of user takes it, so balance checks stay true until commit
+func (r UserRepository) Lock(id uint) error {
+	return r.orm.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", id).
+		Take(&models.User{}).
+		Error
+}
+
+// Stats returns balance of user, balance is accrued minus withdrawn, held and expired
+func (r UserRepository) Stats(id uint) (*models.Balance, error) {
+	balance := &models.Balance{}
+	err := r.orm.Raw(`
+		SELECT
+			(SELECT coalesce(sum(accrual), 0) FROM orders WHERE user_id = @user AND deleted_at IS NULL) AS current,
+			(SELECT coalesce(sum(amount), 0) FROM point_expirations WHERE user_id = @user) AS expired,
+			coalesce(sum(sum) FILTER (WHERE status = @confirmed), 0) AS withdrawn,
+			coalesce(sum(sum) FILTER (WHERE status = @pending), 0) AS pending
+		FROM withdrawals
+		WHERE user_id = @user AND deleted_at IS NULL`,
+		map[string]any{
+			"user":      id,
+			"confirmed": models.WithdrawalStatusConfirmed,
+			"pending":   models.WithdrawalStatusPending,
+		},
+	).Scan(balance).Error
+	balance.Current -= balance.Withdrawn + balance.Pending + balance.Expired
+	return balance, err
+}
+
+// ExpirationRuns returns expirations of user as they would go by current accruals, oldest first.
+// Spent are withdrawals held now and made before expiration
+func (r UserRepository) ExpirationRuns(id uint) ([]models.ExpirationRun, error) {
+	var runs []models.ExpirationRun
+	err := r.orm.Raw(`
+		SELECT
+			e.cutoff,
+			(
+				SELECT coalesce(sum(accrual), 0) FROM orders
+				WHERE user_id = @user AND accrued_at < e.cutoff AND deleted_at IS NULL
+			) AS accrued,
+			(
+				SELECT coalesce(sum(sum), 0) FROM withdrawals
+				WHERE user_id = @user AND status IN @held AND created_at < e.created_at AND deleted_at IS NULL
+			) AS spent
+		FROM point_expirations e
+		WHERE e.user_id = @user AND e.amount > 0
+		ORDER BY e.created_at`,
+		map[string]any{
+			"user": id,
+			"held": models.WithdrawalStatusesHeld,
+		},
+	).Scan(&runs).Error
+	return runs, err
+}
+
+func (r UserRepository) UpdatePasswordHash(id uint, hash string) error {
+	return r.orm.Model(&models.User{}).Where("id = ?", id).Update("password_hash", hash).Error
+}
+
+// ChangePassword sets password hash and revokes all issued tokens of user
+func (r UserRepository) ChangePassword(id uint, hash string) (*models.User, error) {
+	user := &models.User{}
+	tx := r.orm.Model(user).
+		Clauses(clause.Returning{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"password_hash":   hash,
+			"session_version": gorm.Expr("session_version + 1"),
+		})
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return nil, &models.NotFoundError{Entity: "user"}
+	}
+	return user, nil
+}
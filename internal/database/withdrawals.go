@@ -0,0 +1,97 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/ksusonic/gophermart/internal/events"
+	"github.com/ksusonic/gophermart/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WithdrawalRepository struct {
+	orm *gorm.DB
+}
+
+// Create stores withdrawal, returns models.ConflictError if order is already paid with points.
+// It doesn't check balance, lock user and check it in the same transaction
+func (r WithdrawalRepository) Create(withdrawal *models.Withdrawal) error {
+	err := r.orm.Create(withdrawal).Error
+	if isUniqueViolation(err) {
+		return &models.ConflictError{Entity: "withdrawal", Reason: "order is already paid with points"}
+	}
+	if err != nil {
+		return err
+	}
+	return publish(r.orm, events.PointsWithdrawn{
+		Order:  withdrawal.OrderID,
+		UserID: withdrawal.UserID,
+		Sum:    withdrawal.Sum,
+		Status: withdrawal.Status,
+	})
+}
+
+func (r WithdrawalRepository) GetByOrderID(orderID string) (*models.Withdrawal, error) {
+	withdrawal := &models.Withdrawal{}
+	tx := r.orm.Where("order_id = ?", orderID).Limit(1).Find(withdrawal)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return nil, &models.NotFoundError{Entity: "withdrawal"}
+	}
+	return withdrawal, nil
+}
+
+func (r WithdrawalRepository) ListByUser(userID uint, page models.Page) (*[]models.Withdrawal, error) {
+	withdrawals := &[]models.Withdrawal{}
+	err := paginate(r.orm.Model(&models.Withdrawal{}), page).
+		Where("user_id = ?", userID).
+		Order("created_at, id").
+		Find(withdrawals).
+		Error
+	return withdrawals, err
+}
+
+// Transition moves withdrawal of order from one of statuses to another.
+// Zero userID allows withdrawals of any user.
+// Returns models.NotFoundError if there is no such withdrawal or models.ConflictError
+func (r WithdrawalRepository) Transition(
+	orderID string,
+	userID uint,
+	from []models.WithdrawalStatus,
+	to models.WithdrawalStatus,
+) (*models.Withdrawal, error) {
+	withdrawal := &models.Withdrawal{}
+	tx := r.orm.Model(withdrawal).
+		Clauses(clause.Returning{}).
+		Where("order_id = ? and status in ?", orderID, from)
+	if userID != 0 {
+		tx = tx.Where("user_id = ?", userID)
+	}
+	tx = tx.Update("status", to)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if tx.RowsAffected == 1 {
+		return withdrawal, publish(r.orm, events.WithdrawalStatusChanged{
+			Order:  withdrawal.OrderID,
+			UserID: withdrawal.UserID,
+			Sum:    withdrawal.Sum,
+			Status: withdrawal.Status,
+		})
+	}
+
+	existing, err := r.GetByOrderID(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if userID != 0 && existing.UserID != userID {
+		return nil, &models.NotFoundError{Entity: "withdrawal"}
+	}
+	return nil, &models.ConflictError{
+		Entity: "withdrawal",
+		Reason: fmt.Sprintf("withdrawal is %s and can not become %s", existing.Status, to),
+	}
+}
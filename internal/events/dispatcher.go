@@ -0,0 +1,157 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/utils"
+
+	"go.uber.org/zap"
+)
+
+const (
+	batchSize = 100
+	lease     = time.Minute
+)
+
+type DB interface {
+	ClaimOutboxEvents(ctx context.Context, now time.Time, lease time.Duration, limit int) (*[]models.OutboxEvent, error)
+	CompleteOutboxEvent(ctx context.Context, event *models.OutboxEvent) error
+}
+
+// Handler reacts to event. Delivery is at least once: event is handed again to every
+// subscriber of it if any of them fails, so handlers must tolerate duplicates
+type Handler func(ctx context.Context, record *Record) error
+
+type Config struct {
+	Interval    time.Duration // how often outbox is polled
+	MaxAttempts int           // event is given up after
+	Backoff     time.Duration // first retry delay, doubled for every next one
+	MaxBackoff  time.Duration
+}
+
+type subscriber struct {
+	name    string
+	handler Handler
+}
+
+// Dispatcher delivers events from outbox to in-process subscribers
+type Dispatcher struct {
+	db          DB
+	cfg         Config
+	subscribers map[Type][]subscriber
+	all         []subscriber
+	logger      *zap.SugaredLogger
+}
+
+func NewDispatcher(db DB, cfg Config, logger *zap.SugaredLogger) *Dispatcher {
+	return &Dispatcher{
+		db:          db,
+		cfg:         cfg,
+		subscribers: map[Type][]subscriber{},
+		logger:      logger,
+	}
+}
+
+// Subscribe registers handler for types of events, for all events if no types given.
+// Must be called before Run
+func (d *Dispatcher) Subscribe(name string, handler Handler, types ...Type) {
+	s := subscriber{name: name, handler: handler}
+	if len(types) == 0 {
+		d.all = append(d.all, s)
+		return
+	}
+	for _, t := range types {
+		d.subscribers[t] = append(d.subscribers[t], s)
+	}
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := d.dispatchDue(ctx); err != nil {
+				d.logger.Errorf("could not dispatch events: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) dispatchDue(ctx context.Context) error {
+	rows, err := d.db.ClaimOutboxEvents(ctx, time.Now(), lease, batchSize)
+	if err != nil {
+		return fmt.Errorf("could not claim events: %w", err)
+	}
+
+	// one by one, so subscribers see events in order they happened
+	// on shutdown the rest is left to lease expiry
+	for i := range *rows {
+		if ctx.Err() != nil {
+			return nil
+		}
+		row := &(*rows)[i]
+		err := d.dispatch(ctx, row)
+		d.complete(ctx, row, err)
+	}
+	return nil
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, row *models.OutboxEvent) error {
+	record, err := Decode(row)
+	if err != nil {
+		return err
+	}
+
+	subscribers := make([]subscriber, 0, len(d.subscribers[record.Event.Type()])+len(d.all))
+	subscribers = append(subscribers, d.subscribers[record.Event.Type()]...)
+	subscribers = append(subscribers, d.all...)
+
+	var failed []string
+	for _, s := range subscribers {
+		if err := s.handler(ctx, record); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", s.name, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func (d *Dispatcher) complete(ctx context.Context, row *models.OutboxEvent, err error) {
+	now := time.Now()
+	row.Attempts++
+	switch {
+	case err == nil:
+		row.DispatchedAt = sql.NullTime{Time: now, Valid: true}
+		row.LastError = ""
+	case row.Attempts >= d.cfg.MaxAttempts:
+		row.FailedAt = sql.NullTime{Time: now, Valid: true}
+		row.LastError = err.Error()
+		d.logger.Errorf("giving up event %d %s: %v", row.ID, row.Type, err)
+	default:
+		row.NextDispatchAt = now.Add(utils.Backoff(d.cfg.Backoff, d.cfg.MaxBackoff, row.Attempts))
+		row.LastError = err.Error()
+		d.logger.Warnf("event %d %s failed, retrying: %v", row.ID, row.Type, err)
+	}
+
+	if err := d.db.CompleteOutboxEvent(ctx, row); err != nil {
+		d.logger.Errorf("could not store state of event %d: %v", row.ID, err)
+	}
+}
+
+// LogHandler writes every event to log
+func LogHandler(logger *zap.SugaredLogger) Handler {
+	return func(_ context.Context, record *Record) error {
+		logger.Infow(string(record.Event.Type()), "id", record.ID, "event", record.Event)
+		return nil
+	}
+}
@@ -0,0 +1,123 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/models"
+)
+
+type Type string
+
+const (
+	TypeUserRegistered          Type = "user.registered"
+	TypeOrderUploaded           Type = "order.uploaded"
+	TypeOrderAccrued            Type = "order.accrued"
+	TypeOrderRejected           Type = "order.rejected"
+	TypeOrderAdjusted           Type = "order.adjusted"
+	TypePointsWithdrawn         Type = "points.withdrawn"
+	TypeWithdrawalStatusChanged Type = "withdrawal.status_changed"
+)
+
+// Event is a fact about a state change, amounts are in hundredths of a point
+type Event interface {
+	Type() Type
+}
+
+type UserRegistered struct {
+	UserID uint   `json:"user_id"`
+	Login  string `json:"login"`
+}
+
+type OrderUploaded struct {
+	Number string `json:"number"`
+	UserID uint   `json:"user_id"`
+}
+
+type OrderAccrued struct {
+	Number  string `json:"number"`
+	UserID  uint   `json:"user_id"`
+	Accrual int64  `json:"accrual"`
+}
+
+type OrderRejected struct {
+	Number string `json:"number"`
+	UserID uint   `json:"user_id"`
+}
+
+// OrderAdjusted is published when accrual system corrects or revokes accrual of processed order
+type OrderAdjusted struct {
+	Number       string             `json:"number"`
+	UserID       uint               `json:"user_id"`
+	Status       models.OrderStatus `json:"status"`
+	Before       int64              `json:"before"`
+	After        int64              `json:"after"`
+	BalanceAfter int64              `json:"balance_after"`
+}
+
+type PointsWithdrawn struct {
+	Order  string                  `json:"order"`
+	UserID uint                    `json:"user_id"`
+	Sum    int64                   `json:"sum"`
+	Status models.WithdrawalStatus `json:"status"`
+}
+
+type WithdrawalStatusChanged struct {
+	Order  string                  `json:"order"`
+	UserID uint                    `json:"user_id"`
+	Sum    int64                   `json:"sum"`
+	Status models.WithdrawalStatus `json:"status"`
+}
+
+func (UserRegistered) Type() Type          { return TypeUserRegistered }
+func (OrderUploaded) Type() Type           { return TypeOrderUploaded }
+func (OrderAccrued) Type() Type            { return TypeOrderAccrued }
+func (OrderRejected) Type() Type           { return TypeOrderRejected }
+func (OrderAdjusted) Type() Type           { return TypeOrderAdjusted }
+func (PointsWithdrawn) Type() Type         { return TypePointsWithdrawn }
+func (WithdrawalStatusChanged) Type() Type { return TypeWithdrawalStatusChanged }
+
+var registry = map[Type]func() Event{
+	TypeUserRegistered:          func() Event { return &UserRegistered{} },
+	TypeOrderUploaded:           func() Event { return &OrderUploaded{} },
+	TypeOrderAccrued:            func() Event { return &OrderAccrued{} },
+	TypeOrderRejected:           func() Event { return &OrderRejected{} },
+	TypeOrderAdjusted:           func() Event { return &OrderAdjusted{} },
+	TypePointsWithdrawn:         func() Event { return &PointsWithdrawn{} },
+	TypeWithdrawalStatusChanged: func() Event { return &WithdrawalStatusChanged{} },
+}
+
+// Record is an event read back from outbox
+type Record struct {
+	ID         uint
+	OccurredAt time.Time
+	Event      Event
+}
+
+// Encode returns outbox row of event
+func Encode(event Event, now time.Time) (*models.OutboxEvent, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal %s: %w", event.Type(), err)
+	}
+	return &models.OutboxEvent{
+		CreatedAt:      now,
+		Type:           string(event.Type()),
+		Payload:        payload,
+		NextDispatchAt: now,
+	}, nil
+}
+
+// Decode returns record of outbox row, events are pointers to structs of this package
+func Decode(row *models.OutboxEvent) (*Record, error) {
+	newEvent, ok := registry[Type(row.Type)]
+	if !ok {
+		return nil, fmt.Errorf("unknown event type %s", row.Type)
+	}
+	event := newEvent()
+	if err := json.Unmarshal(row.Payload, event); err != nil {
+		return nil, fmt.Errorf("could not unmarshal %s: %w", row.Type, err)
+	}
+	return &Record{ID: row.ID, OccurredAt: row.CreatedAt, Event: event}, nil
+}
@@ -0,0 +1,102 @@
+package expiry
+
+import (
+	"context"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/models"
+
+	"go.uber.org/zap"
+)
+
+type DB interface {
+	ExpirePoints(ctx context.Context, cutoff time.Time) (int64, error)
+	GetAccruedOrders(ctx context.Context, userID uint) (*[]models.Order, error)
+	CalculateUserStats(ctx context.Context, userID uint) (*models.Balance, error)
+}
+
+// Service expires points ttl after accrual. Zero ttl disables expiration
+type Service struct {
+	db     DB
+	ttl    time.Duration
+	logger *zap.SugaredLogger
+
+	interval time.Duration
+}
+
+func NewService(db DB, ttl, interval time.Duration, logger *zap.SugaredLogger) *Service {
+	return &Service{
+		db:       db,
+		ttl:      ttl,
+		logger:   logger,
+		interval: interval,
+	}
+}
+
+func (s *Service) Run(ctx context.Context) {
+	if s.ttl <= 0 {
+		s.logger.Info("points expiration is disabled")
+		return
+	}
+
+	s.logger.Infof("Started points expiry, ttl %s", s.ttl)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.expire(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Service) expire(ctx context.Context) {
+	users, err := s.db.ExpirePoints(ctx, time.Now().Add(-s.ttl))
+	if err != nil {
+		s.logger.Errorf("could not expire points: %v", err)
+		return
+	}
+	if users > 0 {
+		s.logger.Infof("expired points of %d users", users)
+	}
+}
+
+// Upcoming lists points of user left unspent, by expiration time.
+// Spending consumes oldest points first
+func (s *Service) Upcoming(ctx context.Context, userID uint) ([]models.ExpiringPoints, error) {
+	if s.ttl <= 0 {
+		return nil, nil
+	}
+
+	orders, err := s.db.GetAccruedOrders(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.db.CalculateUserStats(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	spent := stats.Withdrawn + stats.Pending + stats.Expired
+	var upcoming []models.ExpiringPoints
+	for _, order := range *orders {
+		left := order.Accrual.Int64
+		if spent >= left {
+			spent -= left
+			continue
+		}
+		left -= spent
+		spent = 0
+
+		// points of the same second expire together
+		expiresAt := order.AccruedAt.Time.Add(s.ttl).Truncate(time.Second)
+		if n := len(upcoming); n > 0 && upcoming[n-1].ExpiresAt.Equal(expiresAt) {
+			upcoming[n-1].Amount += left
+			continue
+		}
+		upcoming = append(upcoming, models.ExpiringPoints{Amount: left, ExpiresAt: expiresAt})
+	}
+	return upcoming, nil
+}
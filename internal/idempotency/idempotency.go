@@ -0,0 +1,255 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/api"
+	"github.com/ksusonic/gophermart/internal/ctxdata"
+	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/problem"
+	"github.com/ksusonic/gophermart/internal/recorder"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	Header         = "Idempotency-Key"
+	ReplayedHeader = "Idempotent-Replayed"
+
+	maxKeyLength  = 255
+	sweepInterval = time.Minute
+)
+
+// transientHeaders describe a single transmission or request and are not replayed
+var transientHeaders = map[string]bool{
+	// hop-by-hop
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+	// set for the replay again
+	"Content-Length":   true,
+	"Content-Encoding": true,
+	"Content-Type":     true,
+	"Date":             true,
+	"Vary":             true,
+	http.CanonicalHeaderKey(ctxdata.RequestIDHeader): true,
+}
+
+type DB interface {
+	CreateIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) (bool, error)
+	GetIdempotencyKey(ctx context.Context, userID uint, key string) (*models.IdempotencyKey, error)
+	CompleteIdempotencyKey(ctx context.Context, key *models.IdempotencyKey) error
+	DeleteIdempotencyKey(ctx context.Context, userID uint, key string) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context, completedBefore, startedBefore time.Time) (int64, error)
+}
+
+type Middleware struct {
+	db     DB
+	logger *zap.SugaredLogger
+
+	ttl         time.Duration // completed keys are forgotten after ttl
+	lockTimeout time.Duration // keys in progress are taken over after lockTimeout
+}
+
+func NewMiddleware(db DB, ttl, lockTimeout time.Duration, logger *zap.SugaredLogger) *Middleware {
+	return &Middleware{
+		db:          db,
+		logger:      logger,
+		ttl:         ttl,
+		lockTimeout: lockTimeout,
+	}
+}
+
+// Run deletes expired keys every sweepInterval, keys that are never used again
+// would stay forever otherwise
+func (m *Middleware) Run(ctx context.Context) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.sweep(ctx, time.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *Middleware) sweep(ctx context.Context, now time.Time) {
+	deleted, err := m.db.DeleteExpiredIdempotencyKeys(ctx, now.Add(-m.ttl), now.Add(-m.lockTimeout))
+	if err != nil {
+		m.logger.Errorf("could not sweep idempotency keys: %v", err)
+		return
+	}
+	if deleted > 0 {
+		m.logger.Debugf("swept %d expired idempotency keys", deleted)
+	}
+}
+
+// Handler replays stored response for requests repeated with the same Idempotency-Key.
+// Must be used after authentication, keys are scoped by user
+func (m *Middleware) Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader(Header)
+		if key == "" {
+			ctx.Next()
+			return
+		}
+		if len(key) > maxKeyLength {
+			_ = ctx.Error(problem.New(http.StatusBadRequest, problem.CodeIdempotencyKeyTooLong))
+			ctx.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			_ = ctx.Error(problem.New(http.StatusBadRequest, problem.CodeInvalidRequest))
+			ctx.Abort()
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// response of other version must not be replayed, fingerprints of v1 stay as they were
+		route := ctx.FullPath()
+		if version := api.VersionOf(ctx); version != api.V1 {
+			route += fmt.Sprintf(" v%d", version)
+		}
+
+		userID, _ := ctxdata.GetUserID(ctx)
+		record := &models.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			Fingerprint: fingerprint(ctx.Request.Method, route, body),
+		}
+
+		existing, err := m.acquire(ctx.Request.Context(), record)
+		if err != nil {
+			_ = ctx.Error(fmt.Errorf("could not acquire idempotency key: %w", err))
+			ctx.Abort()
+			return
+		}
+		if existing != nil {
+			m.replay(ctx, record, existing)
+			return
+		}
+
+		response := recorder.Record(ctx)
+		ctx.Next()
+
+		// outcome is stored even if client has gone, otherwise the key stays in progress
+		storeCtx := context.Background()
+
+		if status := response.Status(); status >= http.StatusInternalServerError {
+			// failed requests may be retried with the same key
+			if err := m.db.DeleteIdempotencyKey(storeCtx, userID, key); err != nil {
+				m.logger.Errorf("could not release idempotency key %s: %v", key, err)
+			}
+			return
+		}
+
+		record.StatusCode = response.Status()
+		record.ContentType = response.Header().Get("Content-Type")
+		record.Headers = replayedHeaders(response.Header())
+		record.Response = response.Body()
+		if err := m.db.CompleteIdempotencyKey(storeCtx, record); err != nil {
+			m.logger.Errorf("could not store response of idempotency key %s: %v", key, err)
+		}
+	}
+}
+
+// acquire stores record and returns nil, or returns existing record of the same key
+func (m *Middleware) acquire(ctx context.Context, record *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		created, err := m.db.CreateIdempotencyKey(ctx, record)
+		if err != nil {
+			return nil, fmt.Errorf("could not create: %w", err)
+		}
+		if created {
+			return nil, nil
+		}
+
+		existing, err := m.db.GetIdempotencyKey(ctx, record.UserID, record.Key)
+		if errors.Is(err, models.ErrNotFound) {
+			continue // released meanwhile
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not get: %w", err)
+		}
+		if !m.stale(existing) {
+			return existing, nil
+		}
+		if err := m.db.DeleteIdempotencyKey(ctx, existing.UserID, existing.Key); err != nil {
+			return nil, fmt.Errorf("could not delete stale: %w", err)
+		}
+	}
+	return nil, errors.New("could not acquire key after retry")
+}
+
+func (m *Middleware) stale(record *models.IdempotencyKey) bool {
+	age := time.Since(record.CreatedAt)
+	if record.StatusCode == 0 {
+		return age > m.lockTimeout
+	}
+	return age > m.ttl
+}
+
+func (m *Middleware) replay(ctx *gin.Context, record, existing *models.IdempotencyKey) {
+	switch {
+	case existing.Fingerprint != record.Fingerprint:
+		_ = ctx.Error(problem.New(http.StatusUnprocessableEntity, problem.CodeIdempotencyKeyReused))
+		ctx.Abort()
+	case existing.StatusCode == 0:
+		_ = ctx.Error(problem.New(http.StatusConflict, problem.CodeIdempotencyInProgress))
+		ctx.Abort()
+	default:
+		if len(existing.Headers) > 0 {
+			var headers http.Header
+			if err := json.Unmarshal(existing.Headers, &headers); err != nil {
+				m.logger.Errorf("could not decode headers of idempotency key %s: %v", existing.Key, err)
+			}
+			for name, values := range headers {
+				ctx.Writer.Header()[name] = values
+			}
+		}
+		ctx.Header(ReplayedHeader, "true")
+		ctx.Data(existing.StatusCode, existing.ContentType, existing.Response)
+		ctx.Abort()
+	}
+}
+
+// replayedHeaders encodes response headers except transient ones
+func replayedHeaders(header http.Header) []byte {
+	headers := make(http.Header, len(header))
+	for name, values := range header {
+		if !transientHeaders[http.CanonicalHeaderKey(name)] {
+			headers[name] = values
+		}
+	}
+	encoded, err := json.Marshal(headers)
+	if err != nil {
+		return nil
+	}
+	return encoded
+}
+
+func fingerprint(method, route string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + route + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
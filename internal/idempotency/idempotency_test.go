@@ -0,0 +1,276 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/ctxdata"
+	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/problem"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	testUserID  = 1
+	testKey     = "key"
+	testBody    = "79927398713"
+	ttl         = time.Hour
+	lockTimeout = time.Minute
+)
+
+type fakeDB struct {
+	keys map[string]models.IdempotencyKey
+}
+
+func (db *fakeDB) CreateIdempotencyKey(_ context.Context, key *models.IdempotencyKey) (bool, error) {
+	if _, ok := db.keys[key.Key]; ok {
+		return false, nil
+	}
+	key.CreatedAt = time.Now()
+	db.keys[key.Key] = *key
+	return true, nil
+}
+
+func (db *fakeDB) GetIdempotencyKey(_ context.Context, _ uint, key string) (*models.IdempotencyKey, error) {
+	record, ok := db.keys[key]
+	if !ok {
+		return nil, &models.NotFoundError{Entity: "idempotency key"}
+	}
+	return &record, nil
+}
+
+func (db *fakeDB) CompleteIdempotencyKey(_ context.Context, key *models.IdempotencyKey) error {
+	record := db.keys[key.Key]
+	record.StatusCode = key.StatusCode
+	record.ContentType = key.ContentType
+	record.Headers = key.Headers
+	record.Response = key.Response
+	db.keys[key.Key] = record
+	return nil
+}
+
+func (db *fakeDB) DeleteIdempotencyKey(_ context.Context, _ uint, key string) error {
+	delete(db.keys, key)
+	return nil
+}
+
+func (db *fakeDB) DeleteExpiredIdempotencyKeys(_ context.Context, completedBefore, startedBefore time.Time) (int64, error) {
+	var deleted int64
+	for key, record := range db.keys {
+		before := completedBefore
+		if record.StatusCode == 0 {
+			before = startedBefore
+		}
+		if record.CreatedAt.Before(before) {
+			delete(db.keys, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// testServer serves POST /orders with handler behind idempotency of user testUserID
+type testServer struct {
+	db     *fakeDB
+	engine *gin.Engine
+	calls  int
+}
+
+func newTestServer(handler gin.HandlerFunc) *testServer {
+	gin.SetMode(gin.TestMode)
+	s := &testServer{db: &fakeDB{keys: map[string]models.IdempotencyKey{}}}
+	m := NewMiddleware(s.db, ttl, lockTimeout, zap.NewNop().Sugar())
+
+	s.engine = gin.New()
+	s.engine.Use(func(ctx *gin.Context) {
+		ctxdata.SetUserID(ctx, testUserID)
+		ctx.Next()
+		// render errors by status only, as ErrorHandler would
+		if err := ctx.Errors.Last(); err != nil {
+			var p *problem.Error
+			if !errors.As(err.Err, &p) {
+				p = problem.New(http.StatusInternalServerError, problem.CodeInternal)
+			}
+			ctx.Status(p.Status)
+		}
+	})
+	s.engine.POST("/orders", m.Handler(), func(ctx *gin.Context) {
+		s.calls++
+		handler(ctx)
+	})
+	return s
+}
+
+func (s *testServer) post(key, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	if key != "" {
+		request.Header.Set(Header, key)
+	}
+	recorder := httptest.NewRecorder()
+	s.engine.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestReplaysResponseWithHeaders(t *testing.T) {
+	s := newTestServer(func(ctx *gin.Context) {
+		ctx.Header("Location", "/api/user/orders/79927398713")
+		ctx.Header("Retry-After", "30")
+		ctx.Header("X-RateLimit-Remaining", "9")
+		ctx.Header("X-Request-ID", "original")
+		ctx.Header("Connection", "close")
+		ctx.Writer.Header().Add("Set-Cookie", "a=1")
+		ctx.Writer.Header().Add("Set-Cookie", "b=2")
+		ctx.JSON(http.StatusAccepted, gin.H{"number": testBody})
+	})
+
+	first := s.post(testKey, testBody)
+	replayed := s.post(testKey, testBody)
+
+	if s.calls != 1 {
+		t.Fatalf("handler called %d times, want once", s.calls)
+	}
+	if replayed.Code != first.Code || replayed.Body.String() != first.Body.String() {
+		t.Errorf("replayed %d %s, want %d %s", replayed.Code, replayed.Body, first.Code, first.Body)
+	}
+	if replayed.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("replayed response is not marked")
+	}
+	if got := replayed.Header().Get("Content-Type"); got != first.Header().Get("Content-Type") {
+		t.Errorf("got content type %q, want %q", got, first.Header().Get("Content-Type"))
+	}
+	for _, name := range []string{"Location", "Retry-After", "X-RateLimit-Remaining"} {
+		if got, want := replayed.Header().Get(name), first.Header().Get(name); got != want {
+			t.Errorf("got %s %q, want %q", name, got, want)
+		}
+	}
+	if got := replayed.Header().Values("Set-Cookie"); len(got) != 2 || got[0] != "a=1" || got[1] != "b=2" {
+		t.Errorf("got cookies %v, want both of original response", got)
+	}
+	for _, name := range []string{"Connection", "X-Request-ID"} {
+		if got := replayed.Header().Get(name); got != "" {
+			t.Errorf("transient header %s %q is replayed", name, got)
+		}
+	}
+}
+
+func TestRejectsKeyInProgress(t *testing.T) {
+	s := newTestServer(func(ctx *gin.Context) {
+		ctx.Status(http.StatusAccepted)
+	})
+	s.db.keys[testKey] = models.IdempotencyKey{
+		UserID:      testUserID,
+		Key:         testKey,
+		CreatedAt:   time.Now(),
+		Fingerprint: fingerprint(http.MethodPost, "/orders", []byte(testBody)),
+	}
+
+	if response := s.post(testKey, testBody); response.Code != http.StatusConflict {
+		t.Errorf("got status %d, want %d", response.Code, http.StatusConflict)
+	}
+	if s.calls != 0 {
+		t.Errorf("handler called %d times while key is in progress", s.calls)
+	}
+}
+
+func TestTakesOverStaleKeyInProgress(t *testing.T) {
+	s := newTestServer(func(ctx *gin.Context) {
+		ctx.Status(http.StatusAccepted)
+	})
+	s.db.keys[testKey] = models.IdempotencyKey{
+		UserID:      testUserID,
+		Key:         testKey,
+		CreatedAt:   time.Now().Add(-lockTimeout - time.Second),
+		Fingerprint: fingerprint(http.MethodPost, "/orders", []byte(testBody)),
+	}
+
+	if response := s.post(testKey, testBody); response.Code != http.StatusAccepted {
+		t.Errorf("got status %d, want %d", response.Code, http.StatusAccepted)
+	}
+	if s.calls != 1 {
+		t.Errorf("handler called %d times, want once", s.calls)
+	}
+}
+
+func TestRejectsKeyReusedForOtherRequest(t *testing.T) {
+	s := newTestServer(func(ctx *gin.Context) {
+		ctx.Status(http.StatusAccepted)
+	})
+
+	s.post(testKey, testBody)
+	if response := s.post(testKey, "2377225624"); response.Code != http.StatusUnprocessableEntity {
+		t.Errorf("got status %d, want %d", response.Code, http.StatusUnprocessableEntity)
+	}
+	if s.calls != 1 {
+		t.Errorf("handler called %d times, want once", s.calls)
+	}
+}
+
+func TestReleasesKeyOfFailedRequest(t *testing.T) {
+	status := http.StatusInternalServerError
+	s := newTestServer(func(ctx *gin.Context) {
+		ctx.Status(status)
+	})
+
+	if response := s.post(testKey, testBody); response.Code != http.StatusInternalServerError {
+		t.Fatalf("got status %d, want %d", response.Code, http.StatusInternalServerError)
+	}
+	if _, ok := s.db.keys[testKey]; ok {
+		t.Fatal("key of failed request is kept")
+	}
+
+	status = http.StatusAccepted
+	if response := s.post(testKey, testBody); response.Code != http.StatusAccepted {
+		t.Errorf("got status %d of retry, want %d", response.Code, http.StatusAccepted)
+	}
+	if s.calls != 2 {
+		t.Errorf("handler called %d times, want retry to be handled", s.calls)
+	}
+}
+
+func TestWithoutKey(t *testing.T) {
+	s := newTestServer(func(ctx *gin.Context) {
+		ctx.Status(http.StatusAccepted)
+	})
+
+	s.post("", testBody)
+	s.post("", testBody)
+	if s.calls != 2 {
+		t.Errorf("handler called %d times, want every request without key handled", s.calls)
+	}
+	if len(s.db.keys) != 0 {
+		t.Errorf("got %d stored keys, want none", len(s.db.keys))
+	}
+}
+
+func TestSweepsExpiredKeys(t *testing.T) {
+	db := &fakeDB{keys: map[string]models.IdempotencyKey{}}
+	m := NewMiddleware(db, ttl, lockTimeout, zap.NewNop().Sugar())
+	now := time.Now()
+	for key, record := range map[string]models.IdempotencyKey{
+		"completed":         {StatusCode: http.StatusAccepted, CreatedAt: now.Add(-ttl + time.Minute)},
+		"completed expired": {StatusCode: http.StatusAccepted, CreatedAt: now.Add(-ttl - time.Minute)},
+		"in progress":       {CreatedAt: now.Add(-lockTimeout + time.Second)},
+		"in progress stale": {CreatedAt: now.Add(-lockTimeout - time.Second)},
+	} {
+		record.UserID, record.Key = testUserID, key
+		db.keys[key] = record
+	}
+
+	m.sweep(context.Background(), now)
+
+	for _, key := range []string{"completed", "in progress"} {
+		if _, ok := db.keys[key]; !ok {
+			t.Errorf("key %q is swept", key)
+		}
+	}
+	if len(db.keys) != 2 {
+		t.Errorf("got %d keys after sweep, want 2", len(db.keys))
+	}
+}
@@ -0,0 +1,166 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/service"
+
+	"go.uber.org/zap"
+)
+
+type DB interface {
+	ReserveLoginAttempt(
+		ctx context.Context,
+		key string,
+		now time.Time,
+		limit models.LoginLimit,
+	) (attempt *models.LoginAttempt, reserved bool, err error)
+	ReleaseLoginAttempt(ctx context.Context, attempt *models.LoginAttempt) error
+	ResetLoginAttempts(ctx context.Context, key string) error
+}
+
+type Config struct {
+	MaxAttempts   int           // failures per login before lockout
+	MaxIPAttempts int           // failures per client address before lockout
+	BaseLockout   time.Duration // first lockout, doubled for every next failure
+	MaxLockout    time.Duration
+	Window        time.Duration // failures older than window are forgotten
+}
+
+// Guard tracks failed logins in the database, so lockouts are shared across replicas
+type Guard struct {
+	db     DB
+	cfg    Config
+	logger *zap.SugaredLogger
+
+	now func() time.Time
+}
+
+func NewGuard(db DB, cfg Config, logger *zap.SugaredLogger) *Guard {
+	return &Guard{
+		db:     db,
+		cfg:    cfg,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// attempt is a login attempt counted as failed in advance, until it succeeds
+type attempt struct {
+	db      DB
+	lockout time.Duration
+
+	login *models.LoginAttempt
+	ip    *models.LoginAttempt
+}
+
+// Begin reserves attempt from ip to login before credentials are checked, so concurrent attempts can't
+// exceed the limits. If either is locked, the attempt is not counted and retryAfter is how long the lock lasts
+func (g *Guard) Begin(ctx context.Context, login, ip string) (service.LoginAttempt, time.Duration, error) {
+	now := g.now()
+	reserved := &attempt{db: g.db}
+
+	var (
+		locked *models.LoginAttempt
+		err    error
+	)
+	reserved.login, locked, err = g.reserve(ctx, loginKey(login), g.cfg.MaxAttempts, now)
+	if err == nil && locked == nil {
+		reserved.ip, locked, err = g.reserve(ctx, ipKey(ip), g.cfg.MaxIPAttempts, now)
+	}
+	if err != nil || locked != nil {
+		// login may be reserved already
+		g.release(ctx, reserved)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if locked != nil {
+		retryAfter := lockedFor(locked, now)
+		if retryAfter <= 0 {
+			// lock has expired since the reservation was refused
+			retryAfter = time.Second
+		}
+		return nil, retryAfter, nil
+	}
+
+	reserved.lockout = lockedFor(reserved.login, now)
+	if ipLockout := lockedFor(reserved.ip, now); ipLockout > reserved.lockout {
+		reserved.lockout = ipLockout
+	}
+	return reserved, 0, nil
+}
+
+// Lockout is started by this attempt and is in effect unless the attempt succeeds, zero if none
+func (a *attempt) Lockout() time.Duration {
+	return a.lockout
+}
+
+// Succeed forgets failures of login and uncounts the attempt from its address.
+// Failures of the address are kept until window passes
+func (a *attempt) Succeed(ctx context.Context) error {
+	if err := a.db.ResetLoginAttempts(ctx, a.login.Key); err != nil {
+		return err
+	}
+	return a.db.ReleaseLoginAttempt(ctx, a.ip)
+}
+
+// Reset forgets failures of login, as when password is reset
+func (g *Guard) Reset(ctx context.Context, login string) error {
+	return g.db.ResetLoginAttempts(ctx, loginKey(login))
+}
+
+// reserve returns reserved attempt of key, or its lock if it is locked
+func (g *Guard) reserve(
+	ctx context.Context,
+	key string,
+	maxAttempts int,
+	now time.Time,
+) (reservation *models.LoginAttempt, locked *models.LoginAttempt, err error) {
+	attempt, reserved, err := g.db.ReserveLoginAttempt(ctx, key, now, models.LoginLimit{
+		MaxFailures: maxAttempts,
+		Window:      g.cfg.Window,
+		Lockout:     g.cfg.BaseLockout,
+		MaxLockout:  g.cfg.MaxLockout,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not reserve login attempt of %s: %w", key, err)
+	}
+	if !reserved {
+		return nil, attempt, nil
+	}
+	if lockout := lockedFor(attempt, now); lockout > 0 {
+		g.logger.Infof("%s locked for %s after %d failures", key, lockout, attempt.Failures)
+	}
+	return attempt, nil, nil
+}
+
+// release uncounts reserved parts of attempt
+func (g *Guard) release(ctx context.Context, reserved *attempt) {
+	for _, reservation := range []*models.LoginAttempt{reserved.login, reserved.ip} {
+		if reservation == nil {
+			continue
+		}
+		if err := g.db.ReleaseLoginAttempt(ctx, reservation); err != nil {
+			g.logger.Warnf("could not release login attempt of %s: %v", reservation.Key, err)
+		}
+	}
+}
+
+func lockedFor(attempt *models.LoginAttempt, now time.Time) time.Duration {
+	if !attempt.LockedUntil.Valid {
+		return 0
+	}
+	return attempt.LockedUntil.Time.Sub(now)
+}
+
+func loginKey(login string) string {
+	return "login:" + login
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,243 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/models"
+
+	"go.uber.org/zap"
+)
+
+const (
+	testLogin   = "user"
+	testIP      = "192.0.2.1"
+	baseLockout = time.Minute
+	maxLockout  = 4 * time.Minute
+	window      = time.Hour
+)
+
+// fakeDB keeps attempts in memory with semantics of login_attempts queries
+type fakeDB struct {
+	attempts map[string]models.LoginAttempt
+}
+
+func (db *fakeDB) ReserveLoginAttempt(
+	_ context.Context,
+	key string,
+	now time.Time,
+	limit models.LoginLimit,
+) (*models.LoginAttempt, bool, error) {
+	attempt, ok := db.attempts[key]
+	if ok && attempt.LockedUntil.Valid && attempt.LockedUntil.Time.After(now) {
+		return &attempt, false, nil
+	}
+
+	if !ok || attempt.LastFailureAt.Before(now.Add(-limit.Window)) {
+		attempt = models.LoginAttempt{Key: key}
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = now
+	attempt.LockedUntil = sql.NullTime{}
+	if attempt.Failures >= limit.MaxFailures {
+		lockout := limit.Lockout << (attempt.Failures - limit.MaxFailures)
+		if lockout > limit.MaxLockout {
+			lockout = limit.MaxLockout
+		}
+		attempt.LockedUntil = sql.NullTime{Time: now.Add(lockout), Valid: true}
+	}
+	db.attempts[key] = attempt
+	return &attempt, true, nil
+}
+
+func (db *fakeDB) ReleaseLoginAttempt(_ context.Context, reserved *models.LoginAttempt) error {
+	attempt, ok := db.attempts[reserved.Key]
+	if !ok {
+		return nil
+	}
+	if attempt.Failures > 0 {
+		attempt.Failures--
+	}
+	if attempt.LockedUntil == reserved.LockedUntil {
+		attempt.LockedUntil = sql.NullTime{}
+	}
+	db.attempts[reserved.Key] = attempt
+	return nil
+}
+
+func (db *fakeDB) ResetLoginAttempts(_ context.Context, key string) error {
+	delete(db.attempts, key)
+	return nil
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestGuard(maxAttempts, maxIPAttempts int) (*Guard, *fakeDB, *fakeClock) {
+	db := &fakeDB{attempts: map[string]models.LoginAttempt{}}
+	clock := &fakeClock{now: time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)}
+	guard := NewGuard(db, Config{
+		MaxAttempts:   maxAttempts,
+		MaxIPAttempts: maxIPAttempts,
+		BaseLockout:   baseLockout,
+		MaxLockout:    maxLockout,
+		Window:        window,
+	}, zap.NewNop().Sugar())
+	guard.now = clock.Now
+	return guard, db, clock
+}
+
+// fail begins attempt that is never succeeded and returns lockout it started
+func fail(t *testing.T, guard *Guard, login, ip string) time.Duration {
+	t.Helper()
+	attempt, retryAfter, err := guard.Begin(context.Background(), login, ip)
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if retryAfter > 0 {
+		t.Fatalf("%s from %s is locked for %s", login, ip, retryAfter)
+	}
+	return attempt.Lockout()
+}
+
+func TestGuardLocksAfterMaxAttempts(t *testing.T) {
+	guard, db, _ := newTestGuard(3, 100)
+
+	for i := 1; i < 3; i++ {
+		if lockout := fail(t, guard, testLogin, testIP); lockout != 0 {
+			t.Fatalf("failure %d started lockout %s before limit", i, lockout)
+		}
+	}
+	if lockout := fail(t, guard, testLogin, testIP); lockout != baseLockout {
+		t.Fatalf("failure at limit started lockout %s, want %s", lockout, baseLockout)
+	}
+
+	attempt, retryAfter, err := guard.Begin(context.Background(), testLogin, testIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt != nil || retryAfter != baseLockout {
+		t.Fatalf("got attempt %+v and retry after %s of locked login, want retry after %s", attempt, retryAfter, baseLockout)
+	}
+	if failures := db.attempts[loginKey(testLogin)].Failures; failures != 3 {
+		t.Errorf("got %d failures, locked attempts must not be counted", failures)
+	}
+	if failures := db.attempts[ipKey(testIP)].Failures; failures != 3 {
+		t.Errorf("got %d failures of address, attempts refused by login lock must not be counted", failures)
+	}
+
+	// other logins are not affected
+	if lockout := fail(t, guard, "other", testIP); lockout != 0 {
+		t.Errorf("other login got lockout %s", lockout)
+	}
+}
+
+func TestGuardLockWindow(t *testing.T) {
+	guard, _, clock := newTestGuard(2, 100)
+
+	fail(t, guard, testLogin, testIP)
+	fail(t, guard, testLogin, testIP)
+
+	clock.Advance(baseLockout - time.Second)
+	if _, retryAfter, _ := guard.Begin(context.Background(), testLogin, testIP); retryAfter != time.Second {
+		t.Fatalf("got retry after %s before lock expired, want 1s", retryAfter)
+	}
+
+	// every failure after lock expires doubles lockout up to max
+	clock.Advance(time.Second)
+	for _, want := range []time.Duration{2 * baseLockout, maxLockout, maxLockout} {
+		if lockout := fail(t, guard, testLogin, testIP); lockout != want {
+			t.Fatalf("got lockout %s, want %s", lockout, want)
+		}
+		clock.Advance(want)
+	}
+}
+
+func TestGuardForgetsFailuresOutsideWindow(t *testing.T) {
+	guard, _, clock := newTestGuard(3, 100)
+
+	fail(t, guard, testLogin, testIP)
+	fail(t, guard, testLogin, testIP)
+	clock.Advance(window + time.Second)
+
+	if lockout := fail(t, guard, testLogin, testIP); lockout != 0 {
+		t.Errorf("got lockout %s, failures older than window must be forgotten", lockout)
+	}
+}
+
+func TestGuardSucceedResetsLogin(t *testing.T) {
+	guard, db, _ := newTestGuard(3, 100)
+
+	fail(t, guard, testLogin, testIP)
+	fail(t, guard, testLogin, testIP)
+
+	attempt, _, err := guard.Begin(context.Background(), testLogin, testIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt.Lockout() != baseLockout {
+		t.Fatalf("got lockout %s of attempt at limit, want %s", attempt.Lockout(), baseLockout)
+	}
+	if err := attempt.Succeed(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := db.attempts[loginKey(testLogin)]; ok {
+		t.Error("failures of login are kept after success")
+	}
+	if ip := db.attempts[ipKey(testIP)]; ip.Failures != 2 || ip.LockedUntil.Valid {
+		t.Errorf("got address attempts %+v, want successful attempt uncounted and earlier failures kept", ip)
+	}
+	if lockout := fail(t, guard, testLogin, testIP); lockout != 0 {
+		t.Errorf("got lockout %s right after success", lockout)
+	}
+}
+
+func TestGuardReset(t *testing.T) {
+	guard, _, _ := newTestGuard(2, 100)
+
+	fail(t, guard, testLogin, testIP)
+	fail(t, guard, testLogin, testIP)
+	if err := guard.Reset(context.Background(), testLogin); err != nil {
+		t.Fatal(err)
+	}
+
+	if lockout := fail(t, guard, testLogin, testIP); lockout != 0 {
+		t.Errorf("got lockout %s after reset", lockout)
+	}
+}
+
+func TestGuardLocksAddress(t *testing.T) {
+	guard, db, _ := newTestGuard(100, 2)
+
+	fail(t, guard, "first", testIP)
+	if lockout := fail(t, guard, "second", testIP); lockout != baseLockout {
+		t.Fatalf("failure at address limit started lockout %s, want %s", lockout, baseLockout)
+	}
+
+	attempt, retryAfter, err := guard.Begin(context.Background(), "third", testIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt != nil || retryAfter != baseLockout {
+		t.Fatalf("got attempt %+v and retry after %s from locked address", attempt, retryAfter)
+	}
+	if failures := db.attempts[loginKey("third")].Failures; failures != 0 {
+		t.Errorf("got %d failures of login refused by address lock, want reservation released", failures)
+	}
+
+	if lockout := fail(t, guard, "third", "198.51.100.1"); lockout != 0 {
+		t.Errorf("login from other address got lockout %s", lockout)
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// AccrualAdjustment is posted when accrual system changes accrual of already processed order
+type AccrualAdjustment struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+
+	OrderID      string `gorm:"not null;index"`
+	UserID       uint   `gorm:"not null;index"`
+	Before       int64  `gorm:"not null"` // in hundredths of a point
+	After        int64  `gorm:"not null"`
+	Delta        int64  `gorm:"not null"`
+	BalanceAfter int64  `gorm:"not null"`
+	Flagged      bool   `gorm:"not null;index"` // balance went negative and needs manual review
+}
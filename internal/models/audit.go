@@ -0,0 +1,48 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditActionRegister       AuditAction = "user.register"
+	AuditActionLogin          AuditAction = "user.login"
+	AuditActionLoginFailed    AuditAction = "user.login_failed"
+	AuditActionPasswordChange AuditAction = "user.password_changed"
+	AuditActionPasswordReset  AuditAction = "user.password_reset_requested"
+	AuditActionAdminGranted   AuditAction = "user.admin_granted"
+	AuditActionWithdraw       AuditAction = "balance.withdraw"
+	AuditActionWithdrawStatus AuditAction = "withdrawal.status_changed"
+	AuditActionOrderStatus    AuditAction = "order.status_changed"
+	AuditActionOrderAdjusted  AuditAction = "order.accrual_adjusted"
+
+	AuditActionWebhookSubscribed   AuditAction = "webhook.subscribed"
+	AuditActionWebhookUnsubscribed AuditAction = "webhook.unsubscribed"
+)
+
+// AuditEvent is append-only: rows are never updated or deleted
+type AuditEvent struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"not null;index"`
+
+	ActorID   sql.NullInt64 `gorm:"index"` // null for system actors
+	Action    AuditAction   `gorm:"not null;index"`
+	Target    string        `gorm:"index"`
+	IP        string
+	UserAgent string
+	Before    string
+	After     string
+}
+
+type AuditEventFilter struct {
+	ActorID sql.NullInt64
+	Action  AuditAction
+	Target  string
+	From    time.Time
+	To      time.Time
+	Limit   int
+	Offset  int
+}
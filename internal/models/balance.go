@@ -0,0 +1,9 @@
+package models
+
+// Balance of user in hundredths of a point
+type Balance struct {
+	Current   int64 // available, without held withdrawals
+	Withdrawn int64 // confirmed withdrawals
+	Pending   int64 // held withdrawals
+	Expired   int64
+}
@@ -4,5 +4,7 @@ import "github.com/golang-jwt/jwt/v5"
 
 type Claims struct {
 	jwt.RegisteredClaims
-	UserID uint `json:"user_id"`
+	UserID         uint `json:"user_id"`
+	SessionVersion uint `json:"session_version"`
+	Admin          bool `json:"admin,omitempty"`
 }
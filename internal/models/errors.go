@@ -0,0 +1,50 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinels match typed errors below with errors.Is
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrConflict          = errors.New("conflict")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrInvalidNumber     = errors.New("invalid number")
+)
+
+// NotFoundError is returned for entities that don't exist or are not visible to the caller
+type NotFoundError struct {
+	Entity string
+}
+
+func (e *NotFoundError) Error() string        { return e.Entity + " not found" }
+func (e *NotFoundError) Is(target error) bool { return target == ErrNotFound }
+
+// ConflictError is returned when change contradicts current state of entity
+type ConflictError struct {
+	Entity string
+	Reason string
+}
+
+func (e *ConflictError) Error() string        { return e.Reason }
+func (e *ConflictError) Is(target error) bool { return target == ErrConflict }
+
+// InsufficientFundsError amounts are in hundredths of a point
+type InsufficientFundsError struct {
+	Balance  int64
+	Required int64
+}
+
+func (e *InsufficientFundsError) Error() string        { return "insufficient funds" }
+func (e *InsufficientFundsError) Is(target error) bool { return target == ErrInsufficientFunds }
+
+// InvalidNumberError is returned for order numbers of wrong format or checksum
+type InvalidNumberError struct {
+	Number string
+}
+
+func (e *InvalidNumberError) Error() string {
+	return fmt.Sprintf("incorrect order number: %s", e.Number)
+}
+func (e *InvalidNumberError) Is(target error) bool { return target == ErrInvalidNumber }
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// OutboxEvent is a domain event, written in the same transaction as the change it describes
+type OutboxEvent struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"not null"`
+
+	Type    string `gorm:"not null;index"`
+	Payload []byte `gorm:"not null"`
+
+	Attempts       int       `gorm:"not null;default:0"`
+	NextDispatchAt time.Time `gorm:"not null;index"`
+	DispatchedAt   sql.NullTime
+	FailedAt       sql.NullTime // gave up after max attempts
+	LastError      string
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// PointExpiration is posted when points accrued before Cutoff were not spent in time.
+// Amount is negative for offsets posted when accrual of OrderID was lowered after its points expired
+type PointExpiration struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+
+	UserID  uint           `gorm:"not null;index"`
+	OrderID sql.NullString `gorm:"index"`
+	Amount  int64          `gorm:"not null"` // in hundredths of a point
+	Cutoff  time.Time      `gorm:"not null"`
+}
+
+// ExpirationRun is what one expiration of user saw: points accrued before Cutoff
+// and points spent by then, by current accruals
+type ExpirationRun struct {
+	Cutoff  time.Time
+	Accrued int64
+	Spent   int64
+}
+
+// ExpirationExcess returns how much of expired points runs would not expire by current accruals.
+// Every run expires accrued minus spent minus expired so far, so all of them together
+// expire the most one of them saw unspent
+func ExpirationExcess(expired int64, runs []ExpirationRun) int64 {
+	var due int64
+	for _, run := range runs {
+		if unspent := run.Accrued - run.Spent; unspent > due {
+			due = unspent
+		}
+	}
+	if expired <= due {
+		return 0
+	}
+	return expired - due
+}
+
+// ExpiringPoints are unspent points that expire at ExpiresAt
+type ExpiringPoints struct {
+	Amount    int64 // in hundredths of a point
+	ExpiresAt time.Time
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpirationExcess(t *testing.T) {
+	first := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	second := first.Add(24 * time.Hour)
+
+	tests := []struct {
+		name    string
+		expired int64
+		runs    []ExpirationRun
+		want    int64
+	}{
+		{
+			name:    "no expirations",
+			expired: 0,
+			want:    0,
+		},
+		{
+			name:    "expired then revoked",
+			expired: 10000,
+			runs:    []ExpirationRun{{Cutoff: first, Accrued: 0}},
+			want:    10000,
+		},
+		{
+			name:    "expired then reduced",
+			expired: 10000,
+			runs:    []ExpirationRun{{Cutoff: first, Accrued: 6000}},
+			want:    4000,
+		},
+		{
+			name:    "expired then raised",
+			expired: 10000,
+			runs:    []ExpirationRun{{Cutoff: first, Accrued: 15000}},
+			want:    0,
+		},
+		{
+			name:    "spent before revoked",
+			expired: 7000,
+			runs:    []ExpirationRun{{Cutoff: first, Accrued: 0, Spent: 3000}},
+			want:    7000,
+		},
+		{
+			name:    "reduced points were spent",
+			expired: 7000,
+			runs:    []ExpirationRun{{Cutoff: first, Accrued: 9000, Spent: 3000}},
+			want:    1000,
+		},
+		{
+			name:    "other run still expires",
+			expired: 12000,
+			runs: []ExpirationRun{
+				{Cutoff: first, Accrued: 0},
+				{Cutoff: second, Accrued: 5000},
+			},
+			want: 7000,
+		},
+		{
+			name:    "already offset",
+			expired: 0,
+			runs:    []ExpirationRun{{Cutoff: first, Accrued: 0}},
+			want:    0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExpirationExcess(tt.expired, tt.runs); got != tt.want {
+				t.Errorf("got excess %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
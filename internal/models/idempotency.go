@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type IdempotencyKey struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false"`
+	Key       string    `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index"` // expired keys are swept by it
+
+	Fingerprint string `gorm:"not null"` // hash of request the key was first used with
+	StatusCode  int    // zero while request is in progress
+	ContentType string
+	Headers     []byte // JSON of response headers to replay, except per-connection ones
+	Response    []byte
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type LoginAttempt struct {
+	Key           string `gorm:"primaryKey"` // "login:<login>" or "ip:<address>"
+	Failures      int    `gorm:"not null;default:0"`
+	LastFailureAt time.Time
+	LockedUntil   sql.NullTime
+}
+
+// LoginLimit locks key for Lockout, doubled for every next failure up to MaxLockout,
+// once it has MaxFailures failures within Window
+type LoginLimit struct {
+	MaxFailures int
+	Window      time.Duration
+	Lockout     time.Duration
+	MaxLockout  time.Duration
+}
@@ -21,7 +21,13 @@ type Order struct {
 
 	UserID uint `gorm:"not null"`
 
-	Status   OrderStatus
-	Accrual  sql.NullInt64
-	Withdraw sql.NullInt64
+	Status    OrderStatus
+	Accrual   sql.NullInt64
+	AccruedAt sql.NullTime `gorm:"index"` // points expire counting from it
+}
+
+// OrderClaim is a result of atomic order upload
+type OrderClaim struct {
+	OwnerID uint
+	Created bool // false if order was uploaded before, by OwnerID
 }
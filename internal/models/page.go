@@ -0,0 +1,12 @@
+package models
+
+const (
+	DefaultPageLimit = 100
+	MaxPageLimit     = 1000
+)
+
+// Page of list, zero Limit means no limit
+type Page struct {
+	Limit  int
+	Offset int
+}
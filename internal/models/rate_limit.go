@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type RateLimitBucket struct {
+	Key        string  `gorm:"primaryKey"`
+	Tokens     float64 `gorm:"not null"`
+	RefilledAt time.Time
+	// FullAt is when bucket is refilled if left idle, it may be dropped after
+	FullAt time.Time `gorm:"index"`
+}
@@ -6,6 +6,10 @@ type User struct {
 	gorm.Model
 	Login        string `gorm:"not null;unique"`
 	PasswordHash string `gorm:"not null"`
+	Admin        bool   `gorm:"not null;default:false"`
+
+	// SessionVersion is bumped on password change to revoke issued tokens
+	SessionVersion uint `gorm:"not null;default:0"`
 
 	Orders []Order
 }
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type WebhookEvent string
+
+const (
+	WebhookEventOrderUploaded       WebhookEvent = "order.uploaded"
+	WebhookEventOrderProcessed      WebhookEvent = "order.processed"
+	WebhookEventOrderInvalid        WebhookEvent = "order.invalid"
+	WebhookEventOrderAdjusted       WebhookEvent = "order.adjusted"
+	WebhookEventWithdrawalCreated   WebhookEvent = "withdrawal.created"
+	WebhookEventWithdrawalConfirmed WebhookEvent = "withdrawal.confirmed"
+	WebhookEventWithdrawalCancelled WebhookEvent = "withdrawal.cancelled"
+	WebhookEventWithdrawalReversed  WebhookEvent = "withdrawal.reversed"
+)
+
+var WebhookEvents = []WebhookEvent{
+	WebhookEventOrderUploaded,
+	WebhookEventOrderProcessed,
+	WebhookEventOrderInvalid,
+	WebhookEventOrderAdjusted,
+	WebhookEventWithdrawalCreated,
+	WebhookEventWithdrawalConfirmed,
+	WebhookEventWithdrawalCancelled,
+	WebhookEventWithdrawalReversed,
+}
+
+// WebhookSubscription is a partner endpoint receiving events
+type WebhookSubscription struct {
+	gorm.Model
+
+	Partner string `gorm:"not null"`
+	URL     string `gorm:"not null"`
+	Secret  string `gorm:"not null"` // HMAC key, kept as is to sign payloads
+	Events  string `gorm:"not null"` // comma separated, empty means all events
+}
+
+// WebhookDelivery is a pending or finished delivery of event to subscription
+type WebhookDelivery struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+
+	SubscriptionID uint         `gorm:"not null;uniqueIndex:idx_webhook_delivery_event"`
+	EventID        uint         `gorm:"uniqueIndex:idx_webhook_delivery_event"` // outbox event reported
+	Event          WebhookEvent `gorm:"not null"`
+	Payload        []byte       `gorm:"not null"`
+
+	Attempts      int       `gorm:"not null;default:0"`
+	NextAttemptAt time.Time `gorm:"not null;index"`
+	DeliveredAt   sql.NullTime
+	FailedAt      sql.NullTime // gave up after max attempts
+	LastError     string
+
+	AttemptLog []WebhookAttempt `gorm:"foreignKey:DeliveryID"`
+}
+
+// WebhookAttempt is a delivery log entry
+type WebhookAttempt struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+
+	DeliveryID uint `gorm:"not null;index"`
+	StatusCode int
+	Error      string
+	Duration   time.Duration
+}
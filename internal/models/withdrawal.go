@@ -0,0 +1,24 @@
+package models
+
+import "gorm.io/gorm"
+
+type WithdrawalStatus string
+
+const (
+	WithdrawalStatusPending   WithdrawalStatus = "PENDING"   // held until merchant confirms
+	WithdrawalStatusConfirmed WithdrawalStatus = "CONFIRMED" // counts as withdrawn
+	WithdrawalStatusCancelled WithdrawalStatus = "CANCELLED" // hold released
+	WithdrawalStatusReversed  WithdrawalStatus = "REVERSED"  // refunded after confirmation
+)
+
+// WithdrawalStatusesHeld reduce available balance
+var WithdrawalStatusesHeld = []WithdrawalStatus{WithdrawalStatusPending, WithdrawalStatusConfirmed}
+
+type Withdrawal struct {
+	gorm.Model
+	OrderID string `gorm:"not null;uniqueIndex"` // number of order paid with points
+
+	UserID uint             `gorm:"not null;index"`
+	Sum    int64            `gorm:"not null"` // in hundredths of a point
+	Status WithdrawalStatus `gorm:"not null;default:CONFIRMED"`
+}
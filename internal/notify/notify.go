@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// LogNotifier writes notifications to log. It exposes secrets and is meant for local development only
+type LogNotifier struct {
+	logger *zap.SugaredLogger
+}
+
+func NewLogNotifier(logger *zap.SugaredLogger) *LogNotifier {
+	return &LogNotifier{logger: logger}
+}
+
+func (n *LogNotifier) PasswordReset(login, token string, expiresAt time.Time) error {
+	n.logger.Infof("password reset for %s: token %s valid until %s", login, token, expiresAt.Format(time.RFC3339))
+	return nil
+}
+
+// FileNotifier appends notifications to file, one per line
+type FileNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+func (n *FileNotifier) PasswordReset(login, token string, expiresAt time.Time) error {
+	return n.append(fmt.Sprintf(
+		"%s password_reset login=%s token=%s expires_at=%s\n",
+		time.Now().Format(time.RFC3339), login, token, expiresAt.Format(time.RFC3339),
+	))
+}
+
+func (n *FileNotifier) append(line string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	file, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", n.path, err)
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(line)
+	return err
+}
@@ -0,0 +1,79 @@
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed openapi.yaml
+var specYAML []byte
+
+// swaggerHTML is Swagger UI page loading assets served next to it
+//
+//go:embed swagger.html
+var swaggerHTML []byte
+
+// swaggerCSS and swaggerJS are vendored from swagger-ui-dist 5.18.2
+//
+//go:embed swagger-ui/swagger-ui.css
+var swaggerCSS []byte
+
+//go:embed swagger-ui/swagger-ui-bundle.js
+var swaggerJS []byte
+
+// Spec is OpenAPI document of HTTP API
+type Spec struct {
+	doc    *openapi3.T
+	json   []byte
+	router routers.Router
+}
+
+// Load parses embedded document and checks it is valid OpenAPI 3
+func Load() (*Spec, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(specYAML)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse spec: %w", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid spec: %w", err)
+	}
+	specJSON, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode spec: %w", err)
+	}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("could not build router of spec: %w", err)
+	}
+	return &Spec{doc: doc, json: specJSON, router: router}, nil
+}
+
+func (s *Spec) RegisterHandlers(router *gin.RouterGroup) {
+	router.GET("/openapi.json", s.specHandler)
+	router.GET("/docs", s.docsHandler)
+	router.GET("/docs/swagger-ui.css", asset("text/css; charset=utf-8", swaggerCSS))
+	router.GET("/docs/swagger-ui-bundle.js", asset("text/javascript; charset=utf-8", swaggerJS))
+}
+
+func (s *Spec) specHandler(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, gin.MIMEJSON, s.json)
+}
+
+func (s *Spec) docsHandler(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, gin.MIMEHTML+"; charset=utf-8", swaggerHTML)
+}
+
+func asset(contentType string, data []byte) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Data(http.StatusOK, contentType, data)
+	}
+}
@@ -0,0 +1,82 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ksusonic/gophermart/internal/api"
+	"github.com/ksusonic/gophermart/internal/ctxdata"
+	"github.com/ksusonic/gophermart/internal/problem"
+	"github.com/ksusonic/gophermart/internal/recorder"
+
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Validator rejects requests not matching spec with 400 and logs responses not matching it.
+// Routes missing from spec pass unchecked. Must be used before ErrorHandler, so it sees rendered errors
+func (s *Spec) Validator(logger *zap.SugaredLogger) gin.HandlerFunc {
+	return s.validator(logger, func(ctx *gin.Context, err error) {
+		ctxdata.Logger(ctx.Request.Context(), logger).Error(err)
+	})
+}
+
+// validator is Validator calling onMismatch with responses not matching spec
+func (s *Spec) validator(logger *zap.SugaredLogger, onMismatch func(ctx *gin.Context, err error)) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		request := specRequest(ctx.Request)
+		route, pathParams, err := s.router.FindRoute(request)
+		if err != nil {
+			ctx.Next()
+			return
+		}
+		log := ctxdata.Logger(ctx.Request.Context(), logger)
+
+		requestInput := &openapi3filter.RequestValidationInput{
+			Request:    request,
+			PathParams: pathParams,
+			Route:      route,
+			Options: &openapi3filter.Options{
+				// authentication is checked by handlers
+				AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			},
+		}
+		err = openapi3filter.ValidateRequest(ctx.Request.Context(), requestInput)
+		// validation reads body and leaves a copy in request
+		ctx.Request.Body = request.Body
+		if err != nil {
+			log.Infof("request %s %s does not match spec: %v", ctx.Request.Method, route.Path, err)
+			problem.Render(ctx, problem.New(http.StatusBadRequest, problem.CodeInvalidRequest))
+			ctx.Abort()
+			return
+		}
+
+		response := recorder.Record(ctx)
+		ctx.Next()
+
+		responseInput := &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: requestInput,
+			Status:                 response.Status(),
+			Header:                 response.Header(),
+			Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+		}
+		responseInput.SetBodyBytes(response.Body())
+		if err := openapi3filter.ValidateResponse(ctx.Request.Context(), responseInput); err != nil {
+			onMismatch(ctx, fmt.Errorf("response %d of %s %s does not match spec: %w", response.Status(), ctx.Request.Method, route.Path, err))
+		}
+	}
+}
+
+// specRequest returns request to check against spec. Unversioned routes negotiated to v2 are
+// described under v2 path
+func specRequest(r *http.Request) *http.Request {
+	if api.NegotiateVersion(r.Header.Get("Accept"), api.V1) != api.V2 || strings.HasPrefix(r.URL.Path, api.V2Prefix+"/") {
+		return r
+	}
+	clone := r.Clone(r.Context())
+	clone.URL.Path = api.V2Prefix + strings.TrimPrefix(r.URL.Path, "/api")
+	clone.URL.RawPath = ""
+	return clone
+}
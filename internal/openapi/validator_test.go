@@ -0,0 +1,267 @@
+package openapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/api"
+	"github.com/ksusonic/gophermart/internal/config"
+	"github.com/ksusonic/gophermart/internal/controller"
+	"github.com/ksusonic/gophermart/internal/ctxdata"
+	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/server"
+	"github.com/ksusonic/gophermart/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	testUserID     = 1
+	conflictNumber = "79927398713"
+	wrongPassword  = "wrong password"
+)
+
+type fakeAuth struct{}
+
+func (fakeAuth) AuthMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if _, ok := ctxdata.GetUserID(ctx); !ok {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+		}
+	}
+}
+
+func (fakeAuth) AdminMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {}
+}
+
+func (fakeAuth) GetUserID(ctx *gin.Context) (uint, error) {
+	userID, _ := ctxdata.GetUserID(ctx)
+	return userID, nil
+}
+
+type fakeIdempotency struct{}
+
+func (fakeIdempotency) Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {}
+}
+
+type fakeUsers struct{}
+
+func (fakeUsers) session() *service.Session {
+	return &service.Session{
+		User:      &models.User{Login: "user"},
+		Token:     "token",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+}
+
+func (u fakeUsers) Register(context.Context, service.Credentials, service.Client) (*service.Session, error) {
+	return u.session(), nil
+}
+
+func (u fakeUsers) Login(_ context.Context, credentials service.Credentials, _ service.Client) (*service.Session, error) {
+	if credentials.Password == wrongPassword {
+		return nil, service.ErrInvalidCredentials
+	}
+	return u.session(), nil
+}
+
+func (u fakeUsers) ChangePassword(context.Context, uint, string, string, service.Client) (*service.Session, error) {
+	return u.session(), nil
+}
+
+func (fakeUsers) RequestPasswordReset(context.Context, string, service.Client) {}
+
+func (fakeUsers) ResetPassword(context.Context, string, string, service.Client) error {
+	return nil
+}
+
+type fakeOrders struct{}
+
+func (fakeOrders) Upload(_ context.Context, _ uint, number string) (bool, error) {
+	if number == conflictNumber {
+		return false, &models.ConflictError{Entity: "order", Reason: "order was uploaded by another user"}
+	}
+	return true, nil
+}
+
+func (fakeOrders) UploadBatch(_ context.Context, _ uint, numbers []string) ([]service.BatchResult, error) {
+	results := make([]service.BatchResult, 0, len(numbers))
+	for _, number := range numbers {
+		results = append(results, service.BatchResult{Number: number, Result: service.UploadAccepted})
+	}
+	return results, nil
+}
+
+func (fakeOrders) List(_ context.Context, _ uint, page models.Page) ([]models.Order, bool, error) {
+	order := models.Order{ID: "12345678903", UserID: testUserID, Status: models.OrderStatusProcessed}
+	order.Accrual.Int64, order.Accrual.Valid = 50000, true
+	order.CreatedAt = time.Now()
+	return []models.Order{order, {ID: "4561261212345467", UserID: testUserID, Status: models.OrderStatusNew}}, page.Limit > 0, nil
+}
+
+type fakeBalance struct{}
+
+func (fakeBalance) Balance(context.Context, uint) (*models.Balance, error) {
+	return &models.Balance{Current: 50050, Withdrawn: 4200, Pending: 100}, nil
+}
+
+func (fakeBalance) Expiring(context.Context, uint) ([]models.ExpiringPoints, error) {
+	return []models.ExpiringPoints{{Amount: 1500, ExpiresAt: time.Now().Add(24 * time.Hour)}}, nil
+}
+
+func (fakeBalance) Withdraw(context.Context, service.WithdrawInput, service.Client) (*models.Balance, error) {
+	return &models.Balance{Current: 49299, Withdrawn: 4951}, nil
+}
+
+func (fakeBalance) Withdrawals(_ context.Context, _ uint, page models.Page) ([]models.Withdrawal, bool, error) {
+	withdrawal := models.Withdrawal{OrderID: "2377225624", UserID: testUserID, Sum: 751, Status: models.WithdrawalStatusConfirmed}
+	withdrawal.CreatedAt = time.Now()
+	return []models.Withdrawal{withdrawal}, page.Limit > 0, nil
+}
+
+func (fakeBalance) TransitionWithdrawal(_ context.Context, input service.TransitionInput, _ service.Client) (*models.Withdrawal, error) {
+	return &models.Withdrawal{OrderID: input.Order, UserID: input.UserID, Sum: 751, Status: input.To}, nil
+}
+
+// newTestServer serves user routes as main does, with spec validation calling onMismatch
+func newTestServer(t *testing.T, onMismatch func(ctx *gin.Context, err error)) *server.Server {
+	t.Helper()
+	spec, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := zap.NewNop().Sugar()
+
+	s := server.NewServer(&config.Config{}, logger)
+	s.Use(
+		spec.validator(logger, onMismatch),
+		controller.ErrorHandler(logger),
+		func(ctx *gin.Context) {
+			if ctx.GetHeader("Cookie") != "" {
+				ctxdata.SetUserID(ctx, testUserID)
+			}
+		},
+	)
+	s.MountVersioned("/user", controller.NewUserController(
+		fakeAuth{},
+		fakeIdempotency{},
+		fakeUsers{},
+		fakeOrders{},
+		fakeBalance{},
+		logger,
+	))
+	return s
+}
+
+func TestSpecDescribesUserRoutes(t *testing.T) {
+	spec, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, func(*gin.Context, error) {})
+
+	var checked int
+	for _, route := range s.Engine.Routes() {
+		if !strings.HasPrefix(route.Path, "/api/user/") && !strings.HasPrefix(route.Path, api.V2Prefix+"/user/") {
+			continue
+		}
+		checked++
+
+		path := route.Path
+		for _, part := range strings.Split(route.Path, "/") {
+			if strings.HasPrefix(part, ":") {
+				path = strings.Replace(path, part, "{"+part[1:]+"}", 1)
+			}
+		}
+		item := spec.doc.Paths.Find(path)
+		if item == nil {
+			t.Errorf("%s %s is not in spec", route.Method, path)
+			continue
+		}
+		if item.GetOperation(route.Method) == nil {
+			t.Errorf("%s %s is not in spec, only other methods of the path are", route.Method, path)
+		}
+	}
+	if checked == 0 {
+		t.Fatal("no user routes registered")
+	}
+}
+
+func TestValidatorAcceptsResponsesOfUserRoutes(t *testing.T) {
+	versions := []struct {
+		name   string
+		prefix string
+		accept string
+		v2     bool
+	}{
+		{name: "v1", prefix: "/api/user"},
+		{name: "v2", prefix: api.V2Prefix + "/user", v2: true},
+		{name: "v2 negotiated", prefix: "/api/user", accept: api.V2MediaType, v2: true},
+	}
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		bodyV2      string // if v2 takes other body
+		want        int
+	}{
+		{name: "register", method: http.MethodPost, path: "/register", body: `{"login":"user","password":"Secret123"}`, want: http.StatusOK},
+		{name: "login", method: http.MethodPost, path: "/login", body: `{"login":"user","password":"Secret123"}`, want: http.StatusOK},
+		{name: "login with wrong password", method: http.MethodPost, path: "/login", body: `{"login":"user","password":"` + wrongPassword + `"}`, want: http.StatusUnauthorized},
+		{name: "request password reset", method: http.MethodPost, path: "/password/reset", body: `{"login":"user"}`, want: http.StatusAccepted},
+		{name: "confirm password reset", method: http.MethodPost, path: "/password/reset/confirm", body: `{"token":"token","new_password":"Secret456"}`, want: http.StatusOK},
+		{name: "change password", method: http.MethodPost, path: "/password", body: `{"current_password":"Secret123","new_password":"Secret456"}`, want: http.StatusOK},
+		{name: "upload order", method: http.MethodPost, path: "/orders", contentType: "text/plain", body: "12345678903", want: http.StatusAccepted},
+		{name: "upload order of other user", method: http.MethodPost, path: "/orders", contentType: "text/plain", body: conflictNumber, want: http.StatusConflict},
+		{name: "upload batch", method: http.MethodPost, path: "/orders/batch", body: `["12345678903","4561261212345467"]`, want: http.StatusOK},
+		{name: "list orders", method: http.MethodGet, path: "/orders", want: http.StatusOK},
+		{name: "balance", method: http.MethodGet, path: "/balance", want: http.StatusOK},
+		{name: "expiring points", method: http.MethodGet, path: "/balance/expiring", want: http.StatusOK},
+		{name: "withdraw", method: http.MethodPost, path: "/balance/withdraw", body: `{"order":"2377225624","sum":7.51}`, bodyV2: `{"order":"2377225624","sum":751}`, want: http.StatusOK},
+		{name: "list withdrawals", method: http.MethodGet, path: "/withdrawals", want: http.StatusOK},
+		{name: "cancel withdrawal", method: http.MethodPost, path: "/withdrawals/2377225624/cancel", want: http.StatusOK},
+	}
+
+	for _, version := range versions {
+		for _, tt := range tests {
+			t.Run(version.name+" "+tt.name, func(t *testing.T) {
+				s := newTestServer(t, func(ctx *gin.Context, err error) {
+					t.Error(err)
+				})
+
+				body := tt.body
+				if version.v2 && tt.bodyV2 != "" {
+					body = tt.bodyV2
+				}
+				request := httptest.NewRequest(tt.method, version.prefix+tt.path, strings.NewReader(body))
+				if body != "" {
+					contentType := tt.contentType
+					if contentType == "" {
+						contentType = "application/json"
+					}
+					request.Header.Set("Content-Type", contentType)
+				}
+				if version.accept != "" {
+					request.Header.Set("Accept", version.accept)
+				}
+				request.AddCookie(&http.Cookie{Name: "Authorization", Value: "token"})
+
+				recorder := httptest.NewRecorder()
+				s.Engine.ServeHTTP(recorder, request)
+
+				if recorder.Code != tt.want {
+					t.Errorf("got status %d, want %d: %s", recorder.Code, tt.want, recorder.Body)
+				}
+			})
+		}
+	}
+}
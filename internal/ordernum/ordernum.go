@@ -0,0 +1,100 @@
+package ordernum
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultRule applies to numbers matching no prefix
+const DefaultRule = "*"
+
+type Validator interface {
+	Valid(number string) bool
+}
+
+type ValidatorFunc func(number string) bool
+
+func (f ValidatorFunc) Valid(number string) bool {
+	return f(number)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Validator)
+)
+
+// Register makes validator available for rules by name
+func Register(name string, validator Validator) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = validator
+}
+
+func Lookup(name string) (Validator, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	validator, ok := registry[name]
+	return validator, ok
+}
+
+type rule struct {
+	prefix    string
+	scheme    string
+	validator Validator
+}
+
+// Resolver validates numbers with scheme of the longest matching prefix.
+// Merchants are told apart by prefixes of their receipt numbers
+type Resolver struct {
+	rules    []rule // longest prefix first
+	fallback *rule
+}
+
+// NewResolver parses "<prefix>=<scheme>" pairs separated by ";", e.g. "77=verhoeff;88=damm;*=luhn".
+// Without "*" rule only numbers with listed prefixes are valid
+func NewResolver(config string) (*Resolver, error) {
+	resolver := &Resolver{}
+	for _, pair := range strings.Split(config, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		prefix, scheme, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("rule %q is not in <prefix>=<scheme> format", pair)
+		}
+		prefix, scheme = strings.TrimSpace(prefix), strings.TrimSpace(scheme)
+
+		validator, ok := Lookup(scheme)
+		if !ok {
+			return nil, fmt.Errorf("unknown order number scheme: %s", scheme)
+		}
+		r := rule{prefix: prefix, scheme: scheme, validator: validator}
+		if prefix == DefaultRule {
+			resolver.fallback = &r
+			continue
+		}
+		if !digitsOnly(prefix) {
+			return nil, fmt.Errorf("prefix %q must consist of digits", prefix)
+		}
+		resolver.rules = append(resolver.rules, r)
+	}
+
+	sort.SliceStable(resolver.rules, func(i, j int) bool {
+		return len(resolver.rules[i].prefix) > len(resolver.rules[j].prefix)
+	})
+	return resolver, nil
+}
+
+func (r *Resolver) Valid(number string) bool {
+	for _, rule := range r.rules {
+		if strings.HasPrefix(number, rule.prefix) {
+			return rule.validator.Valid(number)
+		}
+	}
+	if r.fallback != nil {
+		return r.fallback.validator.Valid(number)
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package ordernum
+
+import "testing"
+
+func TestResolverValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		number string
+		want   bool
+	}{
+		{name: "fallback to luhn", config: "*=luhn", number: "79927398713", want: true},
+		{name: "fallback to luhn rejects invalid", config: "*=luhn", number: "79927398710"},
+		{name: "unknown prefix without fallback", config: "77=verhoeff", number: "79927398713"},
+		{name: "unknown prefix with fallback", config: "77=verhoeff;*=luhn", number: "120014", want: true},
+		{name: "prefix scheme", config: "77=verhoeff;*=luhn", number: "770003", want: true},
+		{name: "prefix does not fall back", config: "77=verhoeff;*=luhn", number: "770008"},
+
+		// 770003 is valid only in verhoeff, 770009 only in damm, 700007 only in damm
+		{name: "longest prefix wins", config: "7=damm;77=verhoeff;*=luhn", number: "770003", want: true},
+		{name: "longest prefix wins regardless of order", config: "77=verhoeff;7=damm;*=luhn", number: "770003", want: true},
+		{name: "shorter prefix not used for longer match", config: "7=damm;77=verhoeff;*=luhn", number: "770009"},
+		{name: "shorter prefix", config: "7=damm;77=verhoeff;*=luhn", number: "700007", want: true},
+		{name: "shorter prefix does not fall back", config: "7=damm;77=verhoeff;*=luhn", number: "700005"},
+
+		{name: "spaces and empty rules", config: " 77 = verhoeff ;; * = luhn ;", number: "770003", want: true},
+		{name: "empty config", config: "", number: "79927398713"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver, err := NewResolver(tt.config)
+			if err != nil {
+				t.Fatalf("NewResolver(%q): %v", tt.config, err)
+			}
+			if got := resolver.Valid(tt.number); got != tt.want {
+				t.Errorf("Valid(%q) = %v, want %v", tt.number, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewResolverErrors(t *testing.T) {
+	for _, config := range []string{
+		"77=unknown",
+		"77verhoeff",
+		"7a=luhn",
+		"=luhn",
+	} {
+		t.Run(config, func(t *testing.T) {
+			if _, err := NewResolver(config); err == nil {
+				t.Errorf("NewResolver(%q) got no error", config)
+			}
+		})
+	}
+}
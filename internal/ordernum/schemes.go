@@ -0,0 +1,95 @@
+package ordernum
+
+import "github.com/ksusonic/gophermart/internal/utils"
+
+func init() {
+	Register("luhn", ValidatorFunc(utils.LuhnValid))
+	Register("verhoeff", ValidatorFunc(verhoeffValid))
+	Register("damm", ValidatorFunc(dammValid))
+	Register("mod97", ValidatorFunc(mod97Valid))
+	Register("none", ValidatorFunc(digitsOnly))
+}
+
+var verhoeffD = [10][10]int{
+	{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+	{1, 2, 3, 4, 0, 6, 7, 8, 9, 5},
+	{2, 3, 4, 0, 1, 7, 8, 9, 5, 6},
+	{3, 4, 0, 1, 2, 8, 9, 5, 6, 7},
+	{4, 0, 1, 2, 3, 9, 5, 6, 7, 8},
+	{5, 9, 8, 7, 6, 0, 4, 3, 2, 1},
+	{6, 5, 9, 8, 7, 1, 0, 4, 3, 2},
+	{7, 6, 5, 9, 8, 2, 1, 0, 4, 3},
+	{8, 7, 6, 5, 9, 3, 2, 1, 0, 4},
+	{9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+}
+
+var verhoeffP = [8][10]int{
+	{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+	{1, 5, 7, 6, 2, 8, 3, 0, 9, 4},
+	{5, 8, 0, 3, 7, 9, 6, 1, 4, 2},
+	{8, 9, 1, 6, 0, 4, 3, 5, 2, 7},
+	{9, 4, 5, 3, 1, 2, 6, 8, 7, 0},
+	{4, 2, 8, 6, 5, 7, 3, 9, 0, 1},
+	{2, 7, 9, 3, 8, 0, 6, 4, 1, 5},
+	{7, 0, 4, 6, 9, 1, 3, 2, 5, 8},
+}
+
+func verhoeffValid(number string) bool {
+	if !digitsOnly(number) {
+		return false
+	}
+	var check int
+	for i := 0; i < len(number); i++ {
+		digit := int(number[len(number)-1-i] - '0')
+		check = verhoeffD[check][verhoeffP[i%8][digit]]
+	}
+	return check == 0
+}
+
+var dammTable = [10][10]int{
+	{0, 3, 1, 7, 5, 9, 8, 6, 4, 2},
+	{7, 0, 9, 2, 1, 5, 4, 8, 6, 3},
+	{4, 2, 0, 6, 8, 7, 1, 3, 5, 9},
+	{1, 7, 5, 0, 9, 8, 3, 4, 2, 6},
+	{6, 1, 2, 3, 0, 4, 5, 9, 7, 8},
+	{3, 6, 7, 4, 2, 0, 9, 5, 8, 1},
+	{5, 8, 6, 9, 7, 2, 0, 1, 3, 4},
+	{8, 9, 4, 5, 3, 6, 2, 0, 1, 7},
+	{9, 4, 3, 8, 6, 1, 7, 2, 0, 5},
+	{2, 5, 8, 1, 4, 3, 6, 7, 9, 0},
+}
+
+func dammValid(number string) bool {
+	if !digitsOnly(number) {
+		return false
+	}
+	var interim int
+	for i := 0; i < len(number); i++ {
+		interim = dammTable[interim][number[i]-'0']
+	}
+	return interim == 0
+}
+
+// mod97Valid checks ISO 7064 MOD 97-10, the scheme of IBAN check digits
+func mod97Valid(number string) bool {
+	if !digitsOnly(number) || len(number) < 3 {
+		return false
+	}
+	var remainder int
+	for i := 0; i < len(number); i++ {
+		remainder = (remainder*10 + int(number[i]-'0')) % 97
+	}
+	return remainder == 1
+}
+
+func digitsOnly(number string) bool {
+	if number == "" {
+		return false
+	}
+	for i := 0; i < len(number); i++ {
+		if number[i] < '0' || number[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
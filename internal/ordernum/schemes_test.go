@@ -0,0 +1,52 @@
+package ordernum
+
+import "testing"
+
+func TestSchemes(t *testing.T) {
+	tests := []struct {
+		name   string
+		valid  func(string) bool
+		number string
+		want   bool
+	}{
+		// check digits of 236, 12345 and 123456789012 from published Verhoeff examples
+		{name: "verhoeff 236", valid: verhoeffValid, number: "2363", want: true},
+		{name: "verhoeff 12345", valid: verhoeffValid, number: "123451", want: true},
+		{name: "verhoeff 123456789012", valid: verhoeffValid, number: "1234567890120", want: true},
+		{name: "verhoeff wrong check digit", valid: verhoeffValid, number: "2364"},
+		{name: "verhoeff transposition", valid: verhoeffValid, number: "3263"},
+		{name: "verhoeff empty", valid: verhoeffValid, number: ""},
+		{name: "verhoeff letter", valid: verhoeffValid, number: "236a"},
+
+		// check digits of 572 and 11294 from published Damm examples
+		{name: "damm 572", valid: dammValid, number: "5724", want: true},
+		{name: "damm 11294", valid: dammValid, number: "112946", want: true},
+		{name: "damm wrong check digit", valid: dammValid, number: "5727"},
+		{name: "damm transposition", valid: dammValid, number: "5742"},
+		{name: "damm empty", valid: dammValid, number: ""},
+		{name: "damm letter", valid: dammValid, number: "57x4"},
+
+		// numeric forms of example IBANs GB82WEST12345698765432 and DE89370400440532013000
+		{name: "mod97 GB IBAN", valid: mod97Valid, number: "3214282912345698765432161182", want: true},
+		{name: "mod97 DE IBAN", valid: mod97Valid, number: "370400440532013000131489", want: true},
+		{name: "mod97 wrong check digits", valid: mod97Valid, number: "3214282912345698765432161183"},
+		{name: "mod97 too short", valid: mod97Valid, number: "1"},
+		{name: "mod97 empty", valid: mod97Valid, number: ""},
+		{name: "mod97 letter", valid: mod97Valid, number: "GB82"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.valid(tt.number); got != tt.want {
+				t.Errorf("valid(%q) = %v, want %v", tt.number, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemesRegistered(t *testing.T) {
+	for _, name := range []string{"luhn", "verhoeff", "damm", "mod97", "none"} {
+		if _, ok := Lookup(name); !ok {
+			t.Errorf("scheme %s is not registered", name)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2 is cheap enough for tests, real deployments use far more memory
+var testArgon2 = Argon2Params{Memory: 64, Time: 1, Threads: 1}
+
+const (
+	// classic OpenBSD bcrypt test vector of "U*U"
+	bcryptVector = "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW"
+	// argon2id hash of "Correct-Horse-1" with testArgon2 and salt "saltsaltsaltsalt", as stored by Hash
+	argon2Vector = "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$mLHg0MCvKI8Vlz2vyTfKKwyFXZeRffX5PWeJ1E3KBDs"
+)
+
+func newTestHasher(t *testing.T, algorithm string, bcryptCost int, argon2Params Argon2Params) *Hasher {
+	t.Helper()
+	hasher, err := NewHasher(algorithm, bcryptCost, argon2Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hasher
+}
+
+func TestHasherRoundTrip(t *testing.T) {
+	for _, hasher := range []*Hasher{
+		newTestHasher(t, AlgorithmBcrypt, bcrypt.MinCost, testArgon2),
+		newTestHasher(t, AlgorithmArgon2id, bcrypt.MinCost, testArgon2),
+	} {
+		t.Run(hasher.algorithm, func(t *testing.T) {
+			hash, err := hasher.Hash("Correct-Horse-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !hasher.Compare("Correct-Horse-1", hash) {
+				t.Errorf("password does not match its hash %s", hash)
+			}
+			if hasher.Compare("Correct-Horse-2", hash) {
+				t.Errorf("other password matches hash %s", hash)
+			}
+			if hasher.NeedsRehash(hash) {
+				t.Errorf("fresh hash %s needs rehash", hash)
+			}
+
+			other, err := hasher.Hash("Correct-Horse-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if other == hash {
+				t.Errorf("hashes of the same password are equal, salt is not random")
+			}
+		})
+	}
+}
+
+func TestHasherCompareStoredHashes(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		hash     string
+		want     bool
+	}{
+		{name: "bcrypt", password: "U*U", hash: bcryptVector, want: true},
+		{name: "bcrypt wrong password", password: "U*V", hash: bcryptVector},
+		{name: "argon2id", password: "Correct-Horse-1", hash: argon2Vector, want: true},
+		{name: "argon2id wrong password", password: "Correct-Horse-2", hash: argon2Vector},
+		{name: "argon2id broken key", password: "Correct-Horse-1", hash: strings.Replace(argon2Vector, "mLHg0MCv", "nLHg0MCv", 1)},
+		{name: "argon2id malformed", password: "Correct-Horse-1", hash: "$argon2id$v=19$m=64,t=1,p=1$c2FsdA"},
+		{name: "unknown format", password: "Correct-Horse-1", hash: "Correct-Horse-1"},
+		{name: "empty hash", password: "", hash: ""},
+	}
+	// hashes of both algorithms are verified whatever is configured
+	for _, algorithm := range []string{AlgorithmBcrypt, AlgorithmArgon2id} {
+		hasher := newTestHasher(t, algorithm, bcrypt.DefaultCost, Argon2Params{Memory: 65536, Time: 3, Threads: 4})
+		for _, tt := range tests {
+			t.Run(algorithm+" "+tt.name, func(t *testing.T) {
+				if got := hasher.Compare(tt.password, tt.hash); got != tt.want {
+					t.Errorf("Compare(%q, %q) = %v, want %v", tt.password, tt.hash, got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestDecodeArgon2id(t *testing.T) {
+	params, salt, key, err := decodeArgon2id(argon2Vector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params != testArgon2 || string(salt) != "saltsaltsaltsalt" || len(key) != argon2KeyLen {
+		t.Errorf("got params %+v, salt %q and %d bytes of key", params, salt, len(key))
+	}
+
+	for name, hash := range map[string]string{
+		"empty":          "",
+		"bcrypt":         bcryptVector,
+		"other argon2":   "$argon2i$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$mLHg0MCvKI8Vlz2vyTfKKwyFXZeRffX5PWeJ1E3KBDs",
+		"missing key":    "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA",
+		"extra part":     argon2Vector + "$x",
+		"old version":    strings.Replace(argon2Vector, "v=19", "v=16", 1),
+		"no version":     strings.Replace(argon2Vector, "v=19", "19", 1),
+		"missing params": strings.Replace(argon2Vector, "m=64,t=1,p=1", "m=64,t=1", 1),
+		"bad params":     strings.Replace(argon2Vector, "m=64", "m=x", 1),
+		"bad salt":       strings.Replace(argon2Vector, "c2FsdHNhbHRzYWx0c2FsdA", "c2F*", 1),
+		"bad key":        strings.Replace(argon2Vector, "mLHg0MCv", "mLHg0M!v", 1),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, _, _, err := decodeArgon2id(hash); err == nil {
+				t.Errorf("decoded malformed hash %q", hash)
+			}
+		})
+	}
+}
+
+func TestHasherNeedsRehash(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("Correct-Horse-1"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		algorithm string
+		cost      int
+		argon2    Argon2Params
+		hash      string
+		want      bool
+	}{
+		{name: "bcrypt same cost", algorithm: AlgorithmBcrypt, cost: bcrypt.MinCost, hash: string(bcryptHash)},
+		{name: "bcrypt cost raised", algorithm: AlgorithmBcrypt, cost: bcrypt.MinCost + 1, hash: string(bcryptHash), want: true},
+		{name: "bcrypt cost lowered", algorithm: AlgorithmBcrypt, cost: bcrypt.MinCost, hash: bcryptVector, want: true},
+		{name: "argon2id to bcrypt", algorithm: AlgorithmBcrypt, cost: bcrypt.MinCost, hash: argon2Vector, want: true},
+		{name: "bcrypt malformed", algorithm: AlgorithmBcrypt, cost: bcrypt.MinCost, hash: "$2a$", want: true},
+		{name: "argon2id same params", algorithm: AlgorithmArgon2id, argon2: testArgon2, hash: argon2Vector},
+		{name: "argon2id memory raised", algorithm: AlgorithmArgon2id, argon2: Argon2Params{Memory: 128, Time: 1, Threads: 1}, hash: argon2Vector, want: true},
+		{name: "argon2id time raised", algorithm: AlgorithmArgon2id, argon2: Argon2Params{Memory: 64, Time: 2, Threads: 1}, hash: argon2Vector, want: true},
+		{name: "argon2id threads changed", algorithm: AlgorithmArgon2id, argon2: Argon2Params{Memory: 64, Time: 1, Threads: 2}, hash: argon2Vector, want: true},
+		{name: "bcrypt to argon2id", algorithm: AlgorithmArgon2id, argon2: testArgon2, hash: string(bcryptHash), want: true},
+		{name: "argon2id malformed", algorithm: AlgorithmArgon2id, argon2: testArgon2, hash: "$argon2id$", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cost := tt.cost
+			if cost == 0 {
+				cost = bcrypt.MinCost
+			}
+			argon2Params := tt.argon2
+			if argon2Params == (Argon2Params{}) {
+				argon2Params = testArgon2
+			}
+			hasher := newTestHasher(t, tt.algorithm, cost, argon2Params)
+			if got := hasher.NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash(%q) = %v, want %v", tt.hash, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHasher(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		cost      int
+		argon2    Argon2Params
+		wantErr   bool
+		maxLength int
+	}{
+		{name: "bcrypt", algorithm: AlgorithmBcrypt, cost: bcrypt.DefaultCost, maxLength: bcryptMaxLength},
+		{name: "bcrypt cost too low", algorithm: AlgorithmBcrypt, cost: bcrypt.MinCost - 1, wantErr: true},
+		{name: "bcrypt cost too high", algorithm: AlgorithmBcrypt, cost: bcrypt.MaxCost + 1, wantErr: true},
+		{name: "argon2id", algorithm: AlgorithmArgon2id, argon2: testArgon2, maxLength: argon2MaxLength},
+		{name: "argon2id zero memory", algorithm: AlgorithmArgon2id, argon2: Argon2Params{Time: 1, Threads: 1}, wantErr: true},
+		{name: "argon2id zero time", algorithm: AlgorithmArgon2id, argon2: Argon2Params{Memory: 64, Threads: 1}, wantErr: true},
+		{name: "argon2id zero threads", algorithm: AlgorithmArgon2id, argon2: Argon2Params{Memory: 64, Time: 1}, wantErr: true},
+		{name: "unknown", algorithm: "md5", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasher, err := NewHasher(tt.algorithm, tt.cost, tt.argon2)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && hasher.MaxLength() != tt.maxLength {
+				t.Errorf("got max length %d, want %d", hasher.MaxLength(), tt.maxLength)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package password
+
+// Manager validates new passwords against Policy and hashes them with Hasher
+type Manager struct {
+	Policy
+	*Hasher
+}
+
+func NewManager(policy Policy, hasher *Hasher) *Manager {
+	return &Manager{
+		Policy: policy,
+		Hasher: hasher,
+	}
+}
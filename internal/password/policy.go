@@ -0,0 +1,101 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+var commonPasswords = parseDenylist(commonPasswordsFile)
+
+var (
+	ErrTooShort    = errors.New("password is too short")
+	ErrTooLong     = errors.New("password is too long")
+	ErrTooSimple   = errors.New("password is too simple")
+	ErrCommon      = errors.New("password is too common")
+	ErrSameAsLogin = errors.New("password must differ from login")
+)
+
+// PolicyError tells which rule password breaks, Limit is the value of the rule if it has one
+type PolicyError struct {
+	Err   error
+	Limit int
+}
+
+func (e *PolicyError) Error() string {
+	switch e.Err {
+	case ErrTooShort:
+		return fmt.Sprintf("%v: need at least %d characters", e.Err, e.Limit)
+	case ErrTooLong:
+		return fmt.Sprintf("%v: at most %d bytes allowed", e.Err, e.Limit)
+	case ErrTooSimple:
+		return fmt.Sprintf("%v: need %d of lowercase, uppercase, digits and symbols", e.Err, e.Limit)
+	}
+	return e.Err.Error()
+}
+
+func (e *PolicyError) Unwrap() error {
+	return e.Err
+}
+
+type Policy struct {
+	MinLength  int // in characters
+	MaxLength  int // in bytes, see Hasher.MaxLength
+	MinClasses int // of lower, upper, digits and other characters
+}
+
+func (p Policy) Validate(login, password string) error {
+	if length := utf8.RuneCountInString(password); length < p.MinLength {
+		return &PolicyError{Err: ErrTooShort, Limit: p.MinLength}
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		return &PolicyError{Err: ErrTooLong, Limit: p.MaxLength}
+	}
+	if classes := characterClasses(password); classes < p.MinClasses {
+		return &PolicyError{Err: ErrTooSimple, Limit: p.MinClasses}
+	}
+	if strings.EqualFold(login, password) {
+		return &PolicyError{Err: ErrSameAsLogin}
+	}
+	if _, ok := commonPasswords[strings.ToLower(password)]; ok {
+		return &PolicyError{Err: ErrCommon}
+	}
+	return nil
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, other int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+	return lower + upper + digit + other
+}
+
+func parseDenylist(file string) map[string]struct{} {
+	denylist := make(map[string]struct{})
+	scanner := bufio.NewScanner(strings.NewReader(file))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		denylist[strings.ToLower(line)] = struct{}{}
+	}
+	return denylist
+}
@@ -0,0 +1,62 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPolicyValidate(t *testing.T) {
+	policy := Policy{MinLength: 8, MaxLength: 72, MinClasses: 3}
+	tests := []struct {
+		name      string
+		policy    Policy
+		login     string
+		password  string
+		wantErr   error
+		wantLimit int
+	}{
+		{name: "valid", policy: policy, login: "user", password: "Correct-Horse-1"},
+		{name: "too short", policy: policy, login: "user", password: "Ab1!", wantErr: ErrTooShort, wantLimit: 8},
+		{name: "length in characters", policy: policy, login: "user", password: "Пароль1!"},
+		{name: "too short in characters", policy: policy, login: "user", password: "Пар1!", wantErr: ErrTooShort, wantLimit: 8},
+		{name: "max length", policy: policy, login: "user", password: "Aa1" + strings.Repeat("x", 69)},
+		{name: "too long", policy: policy, login: "user", password: "Aa1" + strings.Repeat("x", 70), wantErr: ErrTooLong, wantLimit: 72},
+		{name: "too long in bytes", policy: policy, login: "user", password: "Aa1" + strings.Repeat("ж", 35), wantErr: ErrTooLong, wantLimit: 72},
+		{name: "no max length", policy: Policy{MinLength: 8, MinClasses: 3}, login: "user", password: "Aa1" + strings.Repeat("x", 1000)},
+		{name: "two classes", policy: policy, login: "user", password: "horsehorse1", wantErr: ErrTooSimple, wantLimit: 3},
+		{name: "symbols count as class", policy: policy, login: "user", password: "horse-horse1"},
+		{name: "same as login", policy: policy, login: "Correct-Horse-1", password: "Correct-Horse-1", wantErr: ErrSameAsLogin},
+		{name: "same as login ignoring case", policy: policy, login: "correct-horse-1", password: "Correct-Horse-1", wantErr: ErrSameAsLogin},
+		{name: "common", policy: policy, login: "user", password: "Password123", wantErr: ErrCommon},
+		{name: "common ignoring case", policy: policy, login: "user", password: "PASSword1", wantErr: ErrCommon},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate(tt.login, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Validate(%q) = %v, want %v", tt.password, err, tt.wantErr)
+			}
+			var policyErr *PolicyError
+			if errors.As(err, &policyErr) && policyErr.Limit != tt.wantLimit {
+				t.Errorf("got limit %d, want %d", policyErr.Limit, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestParseDenylist(t *testing.T) {
+	denylist := parseDenylist("# comment\n\n  Secret  \nletmein\n#notme\n")
+	for _, password := range []string{"secret", "letmein"} {
+		if _, ok := denylist[password]; !ok {
+			t.Errorf("%q is not denied", password)
+		}
+	}
+	if len(denylist) != 2 {
+		t.Errorf("got %d denied passwords, want 2: %v", len(denylist), denylist)
+	}
+
+	if len(commonPasswords) == 0 {
+		t.Error("embedded denylist is empty")
+	}
+}
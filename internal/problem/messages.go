@@ -0,0 +1,151 @@
+package problem
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const DefaultLanguage = "en"
+
+var messages = map[string]map[Code]string{
+	"en": {
+		CodeInternal:           "internal service error",
+		CodeInvalidRequest:     "request is malformed",
+		CodeUnsupportedContent: "unsupported content type %s",
+		CodeRateLimited:        "rate limit exceeded",
+		CodeNotFound:           "not found",
+		CodeConflict:           "conflict with current state",
+
+		CodeUnauthorized:       "authentication required",
+		CodeForbidden:          "access denied",
+		CodeInvalidCredentials: "invalid credentials",
+		CodeLoginLocked:        "too many failed login attempts",
+
+		CodeUserExists: "user already exists",
+
+		CodePasswordTooShort:     "password is too short, need at least %d characters",
+		CodePasswordTooLong:      "password is too long, at most %d bytes allowed",
+		CodePasswordTooSimple:    "password is too simple, need %d of lowercase, uppercase, digits and symbols",
+		CodePasswordCommon:       "password is too common",
+		CodePasswordSameAsLogin:  "password must differ from login",
+		CodePasswordInvalidToken: "invalid or expired token",
+
+		CodeOrderInvalidFormat: "order number must consist of digits",
+		CodeOrderInvalidNumber: "incorrect order number: %s",
+		CodeOrderConflict:      "order is already uploaded by another user",
+		CodeOrderBatchInvalid:  "expected JSON array or lines of order numbers",
+		CodeOrderBatchEmpty:    "no order numbers",
+		CodeOrderBatchTooLarge: "at most %d orders per batch",
+
+		CodeBalanceInsufficient: "insufficient funds",
+
+		CodeWithdrawalInvalidSum:        "sum must be positive",
+		CodeWithdrawalExists:            "order is already paid with points",
+		CodeWithdrawalNotFound:          "withdrawal not found",
+		CodeWithdrawalInvalidTransition: "withdrawal can not become %s",
+
+		CodeIdempotencyKeyTooLong: "idempotency key is too long",
+		CodeIdempotencyKeyReused:  "idempotency key was used with another request",
+		CodeIdempotencyInProgress: "request with this idempotency key is in progress",
+
+		CodeWebhookNotFound:     "subscription not found",
+		CodeWebhookInvalidID:    "invalid subscription id",
+		CodeWebhookUnknownEvent: "unknown event %s",
+		CodeWebhookInvalidURL:   "URL must be https and point to a public address",
+	},
+	"ru": {
+		CodeInternal:           "внутренняя ошибка сервиса",
+		CodeInvalidRequest:     "некорректный запрос",
+		CodeUnsupportedContent: "неподдерживаемый тип содержимого %s",
+		CodeRateLimited:        "превышен лимит запросов",
+		CodeNotFound:           "не найдено",
+		CodeConflict:           "конфликт с текущим состоянием",
+
+		CodeUnauthorized:       "требуется аутентификация",
+		CodeForbidden:          "доступ запрещён",
+		CodeInvalidCredentials: "неверный логин или пароль",
+		CodeLoginLocked:        "слишком много неудачных попыток входа",
+
+		CodeUserExists: "пользователь уже существует",
+
+		CodePasswordTooShort:     "пароль слишком короткий, нужно не меньше %d символов",
+		CodePasswordTooLong:      "пароль слишком длинный, допустимо не больше %d байт",
+		CodePasswordTooSimple:    "пароль слишком простой, нужно %d из строчных, заглавных букв, цифр и символов",
+		CodePasswordCommon:       "пароль слишком распространённый",
+		CodePasswordSameAsLogin:  "пароль должен отличаться от логина",
+		CodePasswordInvalidToken: "токен недействителен или истёк",
+
+		CodeOrderInvalidFormat: "номер заказа должен состоять из цифр",
+		CodeOrderInvalidNumber: "неверный номер заказа: %s",
+		CodeOrderConflict:      "заказ уже загружен другим пользователем",
+		CodeOrderBatchInvalid:  "ожидается JSON-массив или строки с номерами заказов",
+		CodeOrderBatchEmpty:    "нет номеров заказов",
+		CodeOrderBatchTooLarge: "не больше %d заказов за раз",
+
+		CodeBalanceInsufficient: "недостаточно средств",
+
+		CodeWithdrawalInvalidSum:        "сумма должна быть положительной",
+		CodeWithdrawalExists:            "заказ уже оплачен баллами",
+		CodeWithdrawalNotFound:          "списание не найдено",
+		CodeWithdrawalInvalidTransition: "списание нельзя перевести в статус %s",
+
+		CodeIdempotencyKeyTooLong: "ключ идемпотентности слишком длинный",
+		CodeIdempotencyKeyReused:  "ключ идемпотентности уже использован с другим запросом",
+		CodeIdempotencyInProgress: "запрос с этим ключом идемпотентности ещё выполняется",
+
+		CodeWebhookNotFound:     "подписка не найдена",
+		CodeWebhookInvalidID:    "неверный идентификатор подписки",
+		CodeWebhookUnknownEvent: "неизвестное событие %s",
+		CodeWebhookInvalidURL:   "адрес должен быть https и указывать на публичный хост",
+	},
+}
+
+// Message returns message of code in language, falls back to DefaultLanguage
+func Message(code Code, language string, args ...any) string {
+	message, ok := messages[language][code]
+	if !ok {
+		message, ok = messages[DefaultLanguage][code]
+	}
+	if !ok {
+		return string(code)
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// Language picks supported language from Accept-Language header by quality,
+// returns DefaultLanguage if none is acceptable
+func Language(acceptLanguage string) string {
+	type candidate struct {
+		language string
+		quality  float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			parsed, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		// primary subtag is enough, ru-RU is served as ru
+		language, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if _, ok := messages[language]; ok && quality > 0 {
+			candidates = append(candidates, candidate{language: language, quality: quality})
+		}
+	}
+	if len(candidates) == 0 {
+		return DefaultLanguage
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].quality > candidates[j].quality
+	})
+	return candidates[0].language
+}
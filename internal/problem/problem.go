@@ -0,0 +1,75 @@
+package problem
+
+import "net/http"
+
+// Code identifies kind of problem. Clients branch on codes, so they must never change
+type Code string
+
+const (
+	CodeInternal           Code = "internal"
+	CodeInvalidRequest     Code = "request.invalid"
+	CodeUnsupportedContent Code = "request.unsupported_content_type"
+	CodeRateLimited        Code = "request.rate_limited"
+	CodeNotFound           Code = "not_found"
+	CodeConflict           Code = "conflict"
+
+	CodeUnauthorized       Code = "auth.unauthorized"
+	CodeForbidden          Code = "auth.forbidden"
+	CodeInvalidCredentials Code = "auth.invalid_credentials"
+	CodeLoginLocked        Code = "auth.locked"
+
+	CodeUserExists Code = "user.exists"
+
+	CodePasswordTooShort     Code = "password.too_short"
+	CodePasswordTooLong      Code = "password.too_long"
+	CodePasswordTooSimple    Code = "password.too_simple"
+	CodePasswordCommon       Code = "password.common"
+	CodePasswordSameAsLogin  Code = "password.same_as_login"
+	CodePasswordInvalidToken Code = "password.invalid_token"
+
+	CodeOrderInvalidFormat Code = "order.invalid_format"
+	CodeOrderInvalidNumber Code = "order.invalid_number"
+	CodeOrderConflict      Code = "order.conflict"
+	CodeOrderBatchInvalid  Code = "order.batch_invalid"
+	CodeOrderBatchEmpty    Code = "order.batch_empty"
+	CodeOrderBatchTooLarge Code = "order.batch_too_large"
+
+	CodeBalanceInsufficient Code = "balance.insufficient"
+
+	CodeWithdrawalInvalidSum        Code = "withdrawal.invalid_sum"
+	CodeWithdrawalExists            Code = "withdrawal.exists"
+	CodeWithdrawalNotFound          Code = "withdrawal.not_found"
+	CodeWithdrawalInvalidTransition Code = "withdrawal.invalid_transition"
+
+	CodeIdempotencyKeyTooLong Code = "idempotency.key_too_long"
+	CodeIdempotencyKeyReused  Code = "idempotency.key_reused"
+	CodeIdempotencyInProgress Code = "idempotency.in_progress"
+
+	CodeWebhookNotFound     Code = "webhook.not_found"
+	CodeWebhookInvalidID    Code = "webhook.invalid_id"
+	CodeWebhookUnknownEvent Code = "webhook.unknown_event"
+	CodeWebhookInvalidURL   Code = "webhook.invalid_url"
+)
+
+// TypePrefix of problem type URIs, type is prefix followed by code
+const TypePrefix = "urn:gophermart:problem:"
+
+// Error is rendered as problem+json by controller.ErrorHandler.
+// Args are formatted into localized message of Code
+type Error struct {
+	Status int
+	Code   Code
+	Args   []any
+}
+
+func New(status int, code Code, args ...any) *Error {
+	return &Error{Status: status, Code: code, Args: args}
+}
+
+func (e *Error) Error() string {
+	return Message(e.Code, DefaultLanguage, e.Args...)
+}
+
+func (e *Error) Title() string {
+	return http.StatusText(e.Status)
+}
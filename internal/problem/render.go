@@ -0,0 +1,26 @@
+package problem
+
+import (
+	"github.com/ksusonic/gophermart/internal/api"
+	"github.com/ksusonic/gophermart/internal/ctxdata"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Render writes problem+json with message in language of Accept-Language
+func Render(ctx *gin.Context, p *Error) {
+	language := Language(ctx.GetHeader("Accept-Language"))
+	ctx.Header("Content-Type", api.ProblemContentType)
+	ctx.Header("Content-Language", language)
+	// Vary is extended, it may already name Accept of version negotiation
+	ctx.Writer.Header().Add("Vary", "Accept-Language")
+	ctx.JSON(p.Status, api.Problem{
+		Type:      TypePrefix + string(p.Code),
+		Title:     p.Title(),
+		Status:    p.Status,
+		Detail:    Message(p.Code, language, p.Args...),
+		Instance:  ctx.Request.URL.Path,
+		Code:      string(p.Code),
+		RequestID: ctxdata.GetRequestID(ctx),
+	})
+}
@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit of token bucket: Rate tokens per second refill a bucket of Burst tokens
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l Limit) Unlimited() bool {
+	return l.Rate <= 0 || l.Burst <= 0
+}
+
+// ParseLimit parses "<rate>:<burst>", e.g. "0.5:10"
+func ParseLimit(value string) (Limit, error) {
+	rate, burst, ok := strings.Cut(strings.TrimSpace(value), ":")
+	if !ok {
+		return Limit{}, fmt.Errorf("limit %q is not in <rate>:<burst> format", value)
+	}
+	var (
+		limit Limit
+		err   error
+	)
+	if limit.Rate, err = strconv.ParseFloat(rate, 64); err != nil {
+		return Limit{}, fmt.Errorf("invalid rate in %q: %w", value, err)
+	}
+	if limit.Burst, err = strconv.Atoi(burst); err != nil {
+		return Limit{}, fmt.Errorf("invalid burst in %q: %w", value, err)
+	}
+	return limit, nil
+}
+
+// ParseRoutes parses "<METHOD> <path>=<limit>" pairs separated by ";", gRPC routes are full method names,
+// e.g. "POST /api/user/orders=1:10;POST /api/user/login=0.2:5;/gophermart.v1.UserService/Login=0.2:5"
+func ParseRoutes(value string) (map[string]Limit, error) {
+	limits := make(map[string]Limit)
+	for _, pair := range strings.Split(value, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		route, rawLimit, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("route limit %q is not in <route>=<limit> format", pair)
+		}
+		limit, err := ParseLimit(rawLimit)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", route, err)
+		}
+		limits[strings.Join(strings.Fields(route), " ")] = limit
+	}
+	return limits, nil
+}
+
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration // until next token, if not allowed
+	Reset      time.Duration // until bucket is full
+}
+
+// take refills bucket of tokens last updated at last and takes one token from it
+func take(tokens float64, last time.Time, limit Limit, now time.Time) (float64, Result) {
+	elapsed := now.Sub(last).Seconds()
+	if elapsed > 0 {
+		tokens = math.Min(float64(limit.Burst), tokens+elapsed*limit.Rate)
+	}
+
+	var result Result
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	result.Remaining = int(tokens)
+	result.Reset = seconds((float64(limit.Burst) - tokens) / limit.Rate)
+	return tokens, result
+}
+
+func seconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Second))
+}
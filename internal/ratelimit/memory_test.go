@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTake(t *testing.T) {
+	now := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	limit := Limit{Rate: 1, Burst: 3}
+	tests := []struct {
+		name       string
+		tokens     float64
+		elapsed    time.Duration
+		limit      Limit
+		wantTokens float64
+		want       Result
+	}{
+		{
+			name:       "full bucket",
+			tokens:     3,
+			limit:      limit,
+			wantTokens: 2,
+			want:       Result{Allowed: true, Remaining: 2, Reset: time.Second},
+		},
+		{
+			name:       "last token",
+			tokens:     1,
+			limit:      limit,
+			wantTokens: 0,
+			want:       Result{Allowed: true, Remaining: 0, Reset: 3 * time.Second},
+		},
+		{
+			name:       "empty bucket",
+			tokens:     0,
+			limit:      limit,
+			wantTokens: 0,
+			want:       Result{RetryAfter: time.Second, Reset: 3 * time.Second},
+		},
+		{
+			name:       "part of token",
+			tokens:     0.5,
+			limit:      limit,
+			wantTokens: 0.5,
+			want:       Result{RetryAfter: 500 * time.Millisecond, Reset: 2500 * time.Millisecond},
+		},
+		{
+			name:       "refilled by elapsed time",
+			tokens:     0,
+			elapsed:    2 * time.Second,
+			limit:      limit,
+			wantTokens: 1,
+			want:       Result{Allowed: true, Remaining: 1, Reset: 2 * time.Second},
+		},
+		{
+			name:       "refill is capped by burst",
+			tokens:     0,
+			elapsed:    time.Hour,
+			limit:      limit,
+			wantTokens: 2,
+			want:       Result{Allowed: true, Remaining: 2, Reset: time.Second},
+		},
+		{
+			name:       "clock going back does not refill",
+			tokens:     0,
+			elapsed:    -time.Hour,
+			limit:      limit,
+			wantTokens: 0,
+			want:       Result{RetryAfter: time.Second, Reset: 3 * time.Second},
+		},
+		{
+			name:       "slow rate",
+			tokens:     0,
+			elapsed:    time.Second,
+			limit:      Limit{Rate: 0.5, Burst: 2},
+			wantTokens: 0.5,
+			want:       Result{RetryAfter: time.Second, Reset: 3 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, result := take(tt.tokens, now.Add(-tt.elapsed), tt.limit, now)
+			if tokens != tt.wantTokens {
+				t.Errorf("got %v tokens, want %v", tokens, tt.wantTokens)
+			}
+			if result != tt.want {
+				t.Errorf("got %+v, want %+v", result, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	limit := Limit{Rate: 1, Burst: 2}
+	store := NewMemoryStore()
+
+	steps := []struct {
+		key     string
+		at      time.Duration
+		allowed bool
+	}{
+		{key: "a", allowed: true},
+		{key: "a", allowed: true},
+		{key: "a", allowed: false},
+		{key: "b", allowed: true},
+		{key: "a", at: 500 * time.Millisecond, allowed: false},
+		{key: "a", at: time.Second, allowed: true},
+		{key: "a", at: time.Second, allowed: false},
+	}
+	for i, step := range steps {
+		result, err := store.Take(ctx, step.key, limit, now.Add(step.at))
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if result.Allowed != step.allowed {
+			t.Errorf("step %d: take of %s at %s allowed %v, want %v", i, step.key, step.at, result.Allowed, step.allowed)
+		}
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+
+	if _, err := store.Take(ctx, "idle", Limit{Rate: 1, Burst: 2}, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Take(ctx, "slow", Limit{Rate: 0.001, Burst: 2}, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// sweep runs on take once sweepInterval passed
+	if _, err := store.Take(ctx, "other", Limit{Rate: 1, Burst: 2}, now.Add(sweepInterval)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.buckets["idle"]; ok {
+		t.Error("refilled bucket was not swept")
+	}
+	if _, ok := store.buckets["slow"]; !ok {
+		t.Error("bucket still refilling was swept")
+	}
+}
@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/api"
+	"github.com/ksusonic/gophermart/internal/ctxdata"
+	"github.com/ksusonic/gophermart/internal/problem"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// Sweeper is Store that has to be swept of idle buckets periodically
+type Sweeper interface {
+	Sweep(ctx context.Context, now time.Time) (int64, error)
+}
+
+type Limiter struct {
+	store        Store
+	defaultLimit Limit
+	routes       map[string]Limit
+	logger       *zap.SugaredLogger
+}
+
+func NewLimiter(store Store, defaultLimit Limit, routes map[string]Limit, logger *zap.SugaredLogger) *Limiter {
+	return &Limiter{
+		store:        store,
+		defaultLimit: defaultLimit,
+		routes:       routes,
+		logger:       logger,
+	}
+}
+
+// Run sweeps idle buckets of store every sweepInterval, if store needs it
+func (l *Limiter) Run(ctx context.Context) {
+	sweeper, ok := l.store.(Sweeper)
+	if !ok {
+		return
+	}
+
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			deleted, err := sweeper.Sweep(ctx, time.Now())
+			if err != nil {
+				l.logger.Errorf("could not sweep rate limit buckets: %v", err)
+				continue
+			}
+			if deleted > 0 {
+				l.logger.Debugf("swept %d idle rate limit buckets", deleted)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Middleware limits requests per route by user, or by client address for anonymous requests.
+// User is known only if it was identified by previous middleware
+func (l *Limiter) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// versions of route share limit
+		route := ctx.Request.Method + " " + api.Unversioned(ctx.FullPath())
+		userID, _ := ctxdata.GetUserID(ctx)
+		limit, result, limited := l.Take(ctx.Request.Context(), route, userID, ctx.ClientIP())
+		if !limited {
+			ctx.Next()
+			return
+		}
+
+		ctx.Header("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+		ctx.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		ctx.Header("X-RateLimit-Reset", CeilSeconds(result.Reset))
+		if !result.Allowed {
+			ctx.Header("Retry-After", CeilSeconds(result.RetryAfter))
+			_ = ctx.Error(problem.New(http.StatusTooManyRequests, problem.CodeRateLimited))
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// Take takes token of route for user, or for client address if user is zero.
+// Unlimited routes are not limited and no token is taken for them
+func (l *Limiter) Take(ctx context.Context, route string, userID uint, ip string) (limit Limit, result Result, limited bool) {
+	limit, ok := l.routes[route]
+	if !ok {
+		limit = l.defaultLimit
+	}
+	if limit.Unlimited() {
+		return limit, Result{}, false
+	}
+
+	key := "ip:" + ip
+	if userID != 0 {
+		key = "user:" + strconv.FormatUint(uint64(userID), 10)
+	}
+
+	result, err := l.store.Take(ctx, key+" "+route, limit, time.Now())
+	if err != nil {
+		// rather serve than fail on limiter outage
+		l.logger.Errorf("could not take token for %s: %v", key, err)
+		return limit, Result{}, false
+	}
+	return limit, result, true
+}
+
+// CeilSeconds formats duration as whole seconds for Retry-After and X-RateLimit-Reset
+func CeilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/models"
+)
+
+type DB interface {
+	UpdateRateLimitBucket(ctx context.Context, key string, update func(bucket *models.RateLimitBucket) error) error
+	DeleteIdleRateLimitBuckets(ctx context.Context, now time.Time) (int64, error)
+}
+
+// PostgresStore shares buckets between replicas
+type PostgresStore struct {
+	db DB
+}
+
+func NewPostgresStore(db DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	var result Result
+	err := s.db.UpdateRateLimitBucket(ctx, key, func(bucket *models.RateLimitBucket) error {
+		if bucket.RefilledAt.IsZero() {
+			bucket.Tokens = float64(limit.Burst)
+			bucket.RefilledAt = now
+		}
+		bucket.Tokens, result = take(bucket.Tokens, bucket.RefilledAt, limit, now)
+		bucket.RefilledAt = now
+		bucket.FullAt = now.Add(result.Reset)
+		return nil
+	})
+	return result, err
+}
+
+// Sweep drops buckets left idle until refilled
+func (s *PostgresStore) Sweep(ctx context.Context, now time.Time) (int64, error) {
+	return s.db.DeleteIdleRateLimitBuckets(ctx, now)
+}
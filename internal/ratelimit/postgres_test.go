@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/models"
+)
+
+// fakeDB keeps buckets in memory, like rows of rate_limit_buckets
+type fakeDB struct {
+	buckets map[string]models.RateLimitBucket
+}
+
+func (db *fakeDB) UpdateRateLimitBucket(_ context.Context, key string, update func(bucket *models.RateLimitBucket) error) error {
+	bucket, ok := db.buckets[key]
+	if !ok {
+		bucket = models.RateLimitBucket{Key: key}
+	}
+	if err := update(&bucket); err != nil {
+		return err
+	}
+	db.buckets[key] = bucket
+	return nil
+}
+
+func (db *fakeDB) DeleteIdleRateLimitBuckets(_ context.Context, now time.Time) (int64, error) {
+	var deleted int64
+	for key, bucket := range db.buckets {
+		if bucket.FullAt.Before(now) {
+			delete(db.buckets, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func TestPostgresStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	db := &fakeDB{buckets: map[string]models.RateLimitBucket{}}
+	store := NewPostgresStore(db)
+
+	for i := 0; i < 2; i++ {
+		if _, err := store.Take(ctx, "a", Limit{Rate: 1, Burst: 3}, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := db.buckets["a"].FullAt, now.Add(2*time.Second); !got.Equal(want) {
+		t.Fatalf("bucket is full at %s, want %s", got, want)
+	}
+
+	if deleted, err := store.Sweep(ctx, now.Add(time.Second)); err != nil || deleted != 0 {
+		t.Fatalf("swept %d buckets still refilling, error %v", deleted, err)
+	}
+	if deleted, err := store.Sweep(ctx, now.Add(3*time.Second)); err != nil || deleted != 1 {
+		t.Fatalf("swept %d refilled buckets, error %v, want 1", deleted, err)
+	}
+}
@@ -0,0 +1,35 @@
+package recorder
+
+import (
+	"bytes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response passes response through to client and keeps a copy of its body
+type Response struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// Record replaces writer of ctx, so the response written by next handlers is recorded
+func Record(ctx *gin.Context) *Response {
+	response := &Response{ResponseWriter: ctx.Writer}
+	ctx.Writer = response
+	return response
+}
+
+// Body returns what was written so far
+func (r *Response) Body() []byte {
+	return r.body.Bytes()
+}
+
+func (r *Response) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *Response) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,177 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/auth"
+	"github.com/ksusonic/gophermart/internal/ctxdata"
+	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/password"
+	"github.com/ksusonic/gophermart/internal/ratelimit"
+	"github.com/ksusonic/gophermart/internal/rpc/pb"
+	"github.com/ksusonic/gophermart/internal/service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const requestIDKey = "x-request-id"
+
+// anonymous methods are served without token
+var anonymous = map[string]bool{
+	pb.UserService_Register_FullMethodName: true,
+	pb.UserService_Login_FullMethodName:    true,
+}
+
+// requestID keeps x-request-id of client or assigns a new one, and returns it in header
+func requestID(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	id := ctxdata.AcceptRequestID(metadataValue(ctx, requestIDKey))
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, id))
+	return handler(ctxdata.WithRequestID(ctx, id), req)
+}
+
+// accessLog writes one line per call. Must be used after requestID
+func (s *Server) accessLog(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+
+	code := status.Code(err)
+	fields := []any{
+		"request_id", ctxdata.RequestID(ctx),
+		"method", info.FullMethod,
+		"code", code.String(),
+		"latency", time.Since(start),
+		"ip", clientIP(ctx),
+	}
+	log := s.logger.Named("access")
+	switch code {
+	case codes.OK:
+		log.Infow("grpc request", fields...)
+	case codes.Internal, codes.Unknown, codes.Unavailable:
+		log.Errorw("grpc request", fields...)
+	default:
+		log.Warnw("grpc request", fields...)
+	}
+	return resp, err
+}
+
+func (s *Server) recovery(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ctxdata.Logger(ctx, s.logger).Errorw("panic recovered", "method", info.FullMethod, "error", r, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "internal error")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// mapErrors turns errors of handlers into statuses, details of internal errors are only logged
+func (s *Server) mapErrors(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	if err == nil {
+		return resp, nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return nil, err
+	}
+
+	var (
+		policyErr *password.PolicyError
+		locked    *service.LockedError
+	)
+	switch {
+	case errors.As(err, &policyErr):
+		return nil, status.Error(codes.InvalidArgument, policyErr.Error())
+	case errors.Is(err, service.ErrLoginRequired),
+		errors.Is(err, models.ErrInvalidNumber),
+		errors.Is(err, service.ErrInvalidOrderFormat),
+		errors.Is(err, service.ErrInvalidSum),
+		errors.Is(err, service.ErrInvalidPage):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrInvalidCredentials):
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	case errors.As(err, &locked):
+		return nil, status.Error(codes.ResourceExhausted, locked.Error())
+	case errors.Is(err, models.ErrInsufficientFunds):
+		return nil, status.Error(codes.FailedPrecondition, "not enough points")
+	case errors.Is(err, models.ErrNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, models.ErrConflict):
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	}
+	ctxdata.Logger(ctx, s.logger).Errorf("%s: %v", info.FullMethod, err)
+	return nil, status.Error(codes.Internal, "internal error")
+}
+
+// authenticate checks "authorization: Bearer <token>" metadata and puts user ID to context
+func (s *Server) authenticate(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if anonymous[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	token := metadataValue(ctx, "authorization")
+	if len(token) < len("Bearer ") || !strings.EqualFold(token[:len("Bearer ")], "Bearer ") {
+		return nil, status.Error(codes.Unauthenticated, "bearer token required")
+	}
+	user, err := s.auth.Authenticate(ctx, token[len("Bearer "):])
+	if errors.Is(err, auth.ErrInvalidToken) {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	if err != nil {
+		ctxdata.Logger(ctx, s.logger).Errorf("%s: %v", info.FullMethod, err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return handler(ctxdata.WithUserID(ctx, user.ID), req)
+}
+
+// rateLimit limits calls per method by user, or by client address for anonymous calls.
+// Must be used after authenticate
+func (s *Server) rateLimit(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	userID, _ := ctxdata.UserID(ctx)
+	limit, result, limited := s.limiter.Take(ctx, info.FullMethod, userID, clientIP(ctx))
+	if !limited {
+		return handler(ctx, req)
+	}
+
+	header := metadata.Pairs(
+		"x-ratelimit-limit", strconv.Itoa(limit.Burst),
+		"x-ratelimit-remaining", strconv.Itoa(result.Remaining),
+		"x-ratelimit-reset", ratelimit.CeilSeconds(result.Reset),
+	)
+	if !result.Allowed {
+		header.Set("retry-after", ratelimit.CeilSeconds(result.RetryAfter))
+		_ = grpc.SetHeader(ctx, header)
+		return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	_ = grpc.SetHeader(ctx, header)
+	return handler(ctx, req)
+}
+
+func metadataValue(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
@@ -0,0 +1,56 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/ksusonic/gophermart/internal/ctxdata"
+	"github.com/ksusonic/gophermart/internal/ratelimit"
+	"github.com/ksusonic/gophermart/internal/rpc/pb"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestRateLimit(t *testing.T) {
+	limiter := ratelimit.NewLimiter(
+		ratelimit.NewMemoryStore(),
+		ratelimit.Limit{},
+		map[string]ratelimit.Limit{pb.UserService_Login_FullMethodName: {Rate: 0.001, Burst: 2}},
+		zap.NewNop().Sugar(),
+	)
+	s := NewServer(nil, nil, nil, nil, limiter, zap.NewNop().Sugar())
+
+	call := func(ctx context.Context, method string) codes.Code {
+		handler := func(context.Context, any) (any, error) { return nil, nil }
+		_, err := s.rateLimit(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return status.Code(err)
+	}
+	from := func(ip string) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000}})
+	}
+
+	client := from("192.0.2.1")
+	for i := 0; i < 2; i++ {
+		if code := call(client, pb.UserService_Login_FullMethodName); code != codes.OK {
+			t.Fatalf("call %d within burst got %s", i, code)
+		}
+	}
+	if code := call(client, pb.UserService_Login_FullMethodName); code != codes.ResourceExhausted {
+		t.Errorf("call over burst got %s, want %s", code, codes.ResourceExhausted)
+	}
+
+	if code := call(from("198.51.100.1"), pb.UserService_Login_FullMethodName); code != codes.OK {
+		t.Errorf("call from other address got %s", code)
+	}
+	if code := call(ctxdata.WithUserID(client, 1), pb.UserService_Login_FullMethodName); code != codes.OK {
+		t.Errorf("call of user from limited address got %s, users are limited on their own", code)
+	}
+	if code := call(client, pb.UserService_Register_FullMethodName); code != codes.OK {
+		t.Errorf("unlimited method got %s", code)
+	}
+}
@@ -0,0 +1,96 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/ratelimit"
+	"github.com/ksusonic/gophermart/internal/rpc/pb"
+	"github.com/ksusonic/gophermart/internal/service"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// Server serves user operations over gRPC, next to HTTP API
+type Server struct {
+	pb.UnimplementedUserServiceServer
+
+	auth    Auth
+	users   UserService
+	orders  OrderService
+	balance BalanceService
+	limiter RateLimiter
+	logger  *zap.SugaredLogger
+}
+
+type Auth interface {
+	Authenticate(ctx context.Context, token string) (*models.User, error)
+}
+
+type UserService interface {
+	Register(ctx context.Context, credentials service.Credentials, client service.Client) (*service.Session, error)
+	Login(ctx context.Context, credentials service.Credentials, client service.Client) (*service.Session, error)
+}
+
+type OrderService interface {
+	Upload(ctx context.Context, userID uint, number string) (created bool, err error)
+	List(ctx context.Context, userID uint, page models.Page) (orders []models.Order, hasMore bool, err error)
+}
+
+// RateLimiter is shared with HTTP API, methods are limited by their full names
+type RateLimiter interface {
+	Take(ctx context.Context, route string, userID uint, ip string) (limit ratelimit.Limit, result ratelimit.Result, limited bool)
+}
+
+type BalanceService interface {
+	Balance(ctx context.Context, userID uint) (*models.Balance, error)
+	Withdraw(ctx context.Context, input service.WithdrawInput, client service.Client) (*models.Balance, error)
+	Withdrawals(ctx context.Context, userID uint, page models.Page) (withdrawals []models.Withdrawal, hasMore bool, err error)
+}
+
+func NewServer(
+	auth Auth,
+	users UserService,
+	orders OrderService,
+	balance BalanceService,
+	limiter RateLimiter,
+	logger *zap.SugaredLogger,
+) *Server {
+	return &Server{
+		auth:    auth,
+		users:   users,
+		orders:  orders,
+		balance: balance,
+		limiter: limiter,
+		logger:  logger,
+	}
+}
+
+// Run starts serving on address. Returned server is to be stopped by caller
+func (s *Server) Run(address string) (*grpc.Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("could not listen %s: %w", address, err)
+	}
+
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		requestID,
+		s.accessLog,
+		s.recovery,
+		s.mapErrors,
+		s.authenticate,
+		s.rateLimit,
+	))
+	pb.RegisterUserServiceServer(srv, s)
+
+	s.logger.Infof("Starting gRPC server on %s", address)
+	go func() {
+		if err := srv.Serve(listener); err != nil {
+			s.logger.Fatalf("Could not serve gRPC: %v", err)
+		}
+	}()
+	return srv, nil
+}
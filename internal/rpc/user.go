@@ -0,0 +1,148 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ksusonic/gophermart/internal/ctxdata"
+	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/rpc/pb"
+	"github.com/ksusonic/gophermart/internal/service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *Server) Register(ctx context.Context, request *pb.Credentials) (*pb.Session, error) {
+	session, err := s.users.Register(ctx, service.Credentials{
+		Login:    request.Login,
+		Password: request.Password,
+	}, client(ctx))
+	if errors.Is(err, models.ErrConflict) {
+		return nil, status.Error(codes.AlreadyExists, "user already exists")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sessionResponse(session), nil
+}
+
+func (s *Server) Login(ctx context.Context, request *pb.Credentials) (*pb.Session, error) {
+	session, err := s.users.Login(ctx, service.Credentials{
+		Login:    request.Login,
+		Password: request.Password,
+	}, client(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return sessionResponse(session), nil
+}
+
+func (s *Server) UploadOrder(ctx context.Context, request *pb.UploadOrderRequest) (*pb.UploadOrderResponse, error) {
+	userID, _ := ctxdata.UserID(ctx)
+
+	created, err := s.orders.Upload(ctx, userID, request.Number)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.UploadOrderResponse{Created: created}, nil
+}
+
+func (s *Server) ListOrders(ctx context.Context, request *pb.ListRequest) (*pb.ListOrdersResponse, error) {
+	userID, _ := ctxdata.UserID(ctx)
+
+	orders, hasMore, err := s.orders.List(ctx, userID, page(request))
+	if err != nil {
+		return nil, err
+	}
+
+	response := &pb.ListOrdersResponse{HasMore: hasMore}
+	for _, order := range orders {
+		item := &pb.Order{
+			Number:     order.ID,
+			Status:     string(order.Status),
+			UploadedAt: timestamppb.New(order.CreatedAt),
+		}
+		if order.Accrual.Valid {
+			accrual := order.Accrual.Int64
+			item.Accrual = &accrual
+		}
+		response.Orders = append(response.Orders, item)
+	}
+	return response, nil
+}
+
+func (s *Server) GetBalance(ctx context.Context, _ *pb.GetBalanceRequest) (*pb.Balance, error) {
+	userID, _ := ctxdata.UserID(ctx)
+
+	userInfo, err := s.balance.Balance(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return balance(userInfo), nil
+}
+
+func (s *Server) Withdraw(ctx context.Context, request *pb.WithdrawRequest) (*pb.WithdrawResponse, error) {
+	userID, _ := ctxdata.UserID(ctx)
+
+	after, err := s.balance.Withdraw(ctx, service.WithdrawInput{
+		UserID: userID,
+		Order:  request.Order,
+		Sum:    request.Sum,
+		Hold:   request.Hold,
+	}, client(ctx))
+	if errors.Is(err, models.ErrConflict) {
+		return nil, status.Error(codes.AlreadyExists, "order is already paid with points")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pb.WithdrawResponse{Balance: balance(after)}, nil
+}
+
+func (s *Server) ListWithdrawals(ctx context.Context, request *pb.ListRequest) (*pb.ListWithdrawalsResponse, error) {
+	userID, _ := ctxdata.UserID(ctx)
+
+	withdrawals, hasMore, err := s.balance.Withdrawals(ctx, userID, page(request))
+	if err != nil {
+		return nil, err
+	}
+
+	response := &pb.ListWithdrawalsResponse{HasMore: hasMore}
+	for _, withdrawal := range withdrawals {
+		response.Withdrawals = append(response.Withdrawals, &pb.Withdrawal{
+			Order:       withdrawal.OrderID,
+			Sum:         withdrawal.Sum,
+			Status:      string(withdrawal.Status),
+			ProcessedAt: timestamppb.New(withdrawal.CreatedAt),
+		})
+	}
+	return response, nil
+}
+
+// client describes caller of call for services
+func client(ctx context.Context) service.Client {
+	return service.Client{IP: clientIP(ctx), UserAgent: metadataValue(ctx, "user-agent")}
+}
+
+// page returns requested page, of default size if limit is not given
+func page(request *pb.ListRequest) models.Page {
+	limit := int(request.Limit)
+	if limit == 0 {
+		limit = models.DefaultPageLimit
+	}
+	return models.Page{Limit: limit, Offset: int(request.Offset)}
+}
+
+func sessionResponse(session *service.Session) *pb.Session {
+	return &pb.Session{Token: session.Token, ExpiresAt: timestamppb.New(session.ExpiresAt)}
+}
+
+func balance(balance *models.Balance) *pb.Balance {
+	return &pb.Balance{
+		Current:   balance.Current,
+		Withdrawn: balance.Withdrawn,
+		Pending:   balance.Pending,
+	}
+}
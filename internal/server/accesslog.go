@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/ctxdata"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AccessLog writes one line per request. Must be used after RequestID
+func AccessLog(logger *zap.SugaredLogger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		bytes := ctx.Writer.Size()
+		if bytes < 0 {
+			bytes = 0 // nothing written
+		}
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		fields := []any{
+			"request_id", ctxdata.GetRequestID(ctx),
+			"method", ctx.Request.Method,
+			"route", route,
+			"path", ctx.Request.URL.Path,
+			"status", ctx.Writer.Status(),
+			"latency", time.Since(start),
+			"bytes", bytes,
+			"ip", ctx.ClientIP(),
+		}
+		if userID, ok := ctxdata.GetUserID(ctx); ok {
+			fields = append(fields, "user_id", userID)
+		}
+
+		switch status := ctx.Writer.Status(); {
+		case status >= http.StatusInternalServerError:
+			logger.Errorw("request", fields...)
+		case status >= http.StatusBadRequest:
+			logger.Warnw("request", fields...)
+		default:
+			logger.Infow("request", fields...)
+		}
+	}
+}
+
+// Recovery logs panic of handler with its stack and responds with 500
+func Recovery(logger *zap.SugaredLogger) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(ctx *gin.Context, recovered any) {
+		logger.Errorw("panic recovered",
+			"request_id", ctxdata.GetRequestID(ctx),
+			"error", recovered,
+			"stack", string(debug.Stack()),
+		)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
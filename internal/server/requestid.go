@@ -0,0 +1,19 @@
+package server
+
+import (
+	"github.com/ksusonic/gophermart/internal/ctxdata"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestID keeps X-Request-ID of client or assigns a new one, and returns it in response
+func RequestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestID := ctxdata.AcceptRequestID(ctx.GetHeader(ctxdata.RequestIDHeader))
+		ctxdata.SetRequestID(ctx, requestID)
+		// request context carries it to database and other layers
+		ctx.Request = ctx.Request.WithContext(ctxdata.WithRequestID(ctx.Request.Context(), requestID))
+		ctx.Header(ctxdata.RequestIDHeader, requestID)
+		ctx.Next()
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/ksusonic/gophermart/internal/config"
 	"go.uber.org/zap"
 	"net/http"
+	"time"
 )
 
 const apiPrefix = "/api"
@@ -13,11 +14,11 @@ const apiPrefix = "/api"
 type Server struct {
 	Engine *gin.Engine
 	logger *zap.SugaredLogger
+
+	v1Sunset time.Time
 }
 
 func NewServer(cfg *config.Config, logger *zap.SugaredLogger) *Server {
-	r := gin.Default()
-
 	if cfg.Debug {
 		logger.Debugf("loaded cfg: %s", cfg)
 		gin.SetMode(gin.DebugMode)
@@ -25,20 +26,33 @@ func NewServer(cfg *config.Config, logger *zap.SugaredLogger) *Server {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	r.Use(gzip.Gzip(gzip.DefaultCompression))
+	r := gin.New()
+	r.Use(
+		RequestID(),
+		AccessLog(logger.Named("access")),
+		Recovery(logger),
+		gzip.Gzip(gzip.DefaultCompression),
+	)
 
 	_ = r.SetTrustedProxies([]string{})
 
 	return &Server{
-		Engine: r,
-		logger: logger,
+		Engine:   r,
+		logger:   logger,
+		v1Sunset: cfg.APIV1Sunset,
 	}
 }
 
+// Use installs global middleware. Must be called before MountController
+func (s *Server) Use(middleware ...gin.HandlerFunc) {
+	s.Engine.Use(middleware...)
+}
+
 type Controller interface {
 	RegisterHandlers(routerGroup *gin.RouterGroup)
 }
 
+// MountController mounts controller under /api regardless of version
 func (s *Server) MountController(path string, controller Controller) {
 	controller.RegisterHandlers(s.Engine.Group(apiPrefix + path))
 }
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MountVersioned mounts controller under /api/v2 and under /api, where v2 can be asked for by Accept
+func (s *Server) MountVersioned(path string, controller Controller) {
+	v2 := s.Engine.Group(api.V2Prefix + path)
+	v2.Use(func(ctx *gin.Context) {
+		api.SetVersion(ctx, api.V2)
+		ctx.Next()
+	})
+	controller.RegisterHandlers(v2)
+
+	v1 := s.Engine.Group(apiPrefix + path)
+	v1.Use(negotiate(s.v1Sunset))
+	controller.RegisterHandlers(v1)
+}
+
+// negotiate picks version of unversioned route by Accept. Responses of v1 announce its deprecation
+func negotiate(sunset time.Time) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Writer.Header().Add("Vary", "Accept")
+		version := api.NegotiateVersion(ctx.GetHeader("Accept"), api.V1)
+		api.SetVersion(ctx, version)
+		if version == api.V1 {
+			ctx.Header("Deprecation", "true")
+			if !sunset.IsZero() {
+				ctx.Header("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+			successor := api.V2Prefix + strings.TrimPrefix(ctx.Request.URL.Path, apiPrefix)
+			ctx.Header("Link", "<"+successor+`>; rel="successor-version"`)
+		}
+		ctx.Next()
+	}
+}
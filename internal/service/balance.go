@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+
+	"github.com/ksusonic/gophermart/internal/audit"
+	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/utils"
+)
+
+// BalanceService tells balance of users and spends it on withdrawals
+type BalanceService struct {
+	audit     Auditor
+	validator OrderValidator
+	expiry    PointsExpiry
+	db        BalanceDB
+}
+
+type PointsExpiry interface {
+	Upcoming(ctx context.Context, userID uint) ([]models.ExpiringPoints, error)
+}
+
+type BalanceDB interface {
+	InTx(ctx context.Context, fn func(tx Tx) error) error
+
+	CalculateUserStats(ctx context.Context, userID uint) (*models.Balance, error)
+	GetWithdrawalsByUserID(ctx context.Context, userID uint, page models.Page) (*[]models.Withdrawal, error)
+	TransitionWithdrawal(
+		ctx context.Context,
+		orderID string,
+		userID uint,
+		from []models.WithdrawalStatus,
+		to models.WithdrawalStatus,
+	) (*models.Withdrawal, error)
+}
+
+// Tx gives repositories bound to one transaction
+type Tx interface {
+	Users() UserRepository
+	Withdrawals() WithdrawalRepository
+}
+
+type UserRepository interface {
+	// Lock locks user until end of transaction, everything changing balance of user takes it
+	Lock(userID uint) error
+	Stats(userID uint) (*models.Balance, error)
+}
+
+type WithdrawalRepository interface {
+	// Create returns models.ConflictError if order is already paid with points
+	Create(withdrawal *models.Withdrawal) error
+}
+
+// WithdrawInput pays order of user with Sum hundredths of a point.
+// Held withdrawal stays pending until merchant confirms it
+type WithdrawInput struct {
+	UserID uint
+	Order  string
+	Sum    int64
+	Hold   bool
+}
+
+// TransitionInput changes status of withdrawal of Order on behalf of ActorID.
+// Zero UserID allows withdrawals of any user
+type TransitionInput struct {
+	ActorID uint
+	UserID  uint
+	Order   string
+	From    []models.WithdrawalStatus
+	To      models.WithdrawalStatus
+}
+
+func NewBalanceService(auditor Auditor, validator OrderValidator, expiry PointsExpiry, db BalanceDB) *BalanceService {
+	return &BalanceService{
+		audit:     auditor,
+		validator: validator,
+		expiry:    expiry,
+		db:        db,
+	}
+}
+
+func (s *BalanceService) Balance(ctx context.Context, userID uint) (*models.Balance, error) {
+	return s.db.CalculateUserStats(ctx, userID)
+}
+
+// Expiring returns unspent points of user that expire, soonest first
+func (s *BalanceService) Expiring(ctx context.Context, userID uint) ([]models.ExpiringPoints, error) {
+	return s.expiry.Upcoming(ctx, userID)
+}
+
+// Withdraw returns balance after withdrawal. Order paid with points before is a conflict
+func (s *BalanceService) Withdraw(ctx context.Context, input WithdrawInput, client Client) (*models.Balance, error) {
+	orderNumber, err := utils.NormalizeOrderNumber(input.Order)
+	if err != nil || !s.validator.Valid(orderNumber) {
+		return nil, &models.InvalidNumberError{Number: input.Order}
+	}
+	if input.Sum <= 0 {
+		return nil, ErrInvalidSum
+	}
+
+	var withdrawal = models.Withdrawal{
+		OrderID: orderNumber,
+		UserID:  input.UserID,
+		Sum:     input.Sum,
+		Status:  models.WithdrawalStatusConfirmed,
+	}
+	if input.Hold {
+		withdrawal.Status = models.WithdrawalStatusPending
+	}
+
+	// balance is checked and spent in one transaction, user lock serializes withdrawals of user
+	var before, after *models.Balance
+	err = s.db.InTx(ctx, func(tx Tx) (err error) {
+		if err := tx.Users().Lock(input.UserID); err != nil {
+			return err
+		}
+		if before, err = tx.Users().Stats(input.UserID); err != nil {
+			return err
+		}
+		if before.Current < withdrawal.Sum {
+			return &models.InsufficientFundsError{Balance: before.Current, Required: withdrawal.Sum}
+		}
+		if err := tx.Withdrawals().Create(&withdrawal); err != nil {
+			return err
+		}
+		after, err = tx.Users().Stats(input.UserID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	record(s.audit, client, &models.AuditEvent{
+		ActorID: audit.Actor(input.UserID),
+		Action:  models.AuditActionWithdraw,
+		Target:  withdrawal.OrderID,
+		Before:  audit.Balance(before),
+		After:   audit.Balance(after),
+	})
+	return after, nil
+}
+
+// Withdrawals returns page of withdrawals of user and whether there are more after it
+func (s *BalanceService) Withdrawals(
+	ctx context.Context,
+	userID uint,
+	page models.Page,
+) (withdrawals []models.Withdrawal, hasMore bool, err error) {
+	fetch, err := fetchPage(page)
+	if err != nil {
+		return nil, false, err
+	}
+	found, err := s.db.GetWithdrawalsByUserID(ctx, userID, fetch)
+	if err != nil {
+		return nil, false, err
+	}
+	if withdrawals = *found; page.Limit > 0 && len(withdrawals) > page.Limit {
+		return withdrawals[:page.Limit], true, nil
+	}
+	return withdrawals, false, nil
+}
+
+// TransitionWithdrawal returns conflict if withdrawal is not in one of From statuses
+func (s *BalanceService) TransitionWithdrawal(ctx context.Context, input TransitionInput, client Client) (*models.Withdrawal, error) {
+	withdrawal, err := s.db.TransitionWithdrawal(ctx, input.Order, input.UserID, input.From, input.To)
+	if err != nil {
+		return nil, err
+	}
+
+	record(s.audit, client, &models.AuditEvent{
+		ActorID: audit.Actor(input.ActorID),
+		Action:  models.AuditActionWithdrawStatus,
+		Target:  withdrawal.OrderID,
+		Before:  audit.Value(map[string]any{"status": input.From}),
+		After:   audit.Value(map[string]any{"status": withdrawal.Status, "user_id": withdrawal.UserID}),
+	})
+	return withdrawal, nil
+}
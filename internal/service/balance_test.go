@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ksusonic/gophermart/internal/models"
+)
+
+// fakeBalanceDB is its own transaction, changes of failed one are rolled back
+type fakeBalanceDB struct {
+	accrued     int64
+	withdrawals []models.Withdrawal
+	locked      bool
+}
+
+func (db *fakeBalanceDB) InTx(_ context.Context, fn func(tx Tx) error) error {
+	withdrawals := db.withdrawals
+	err := fn(db)
+	if err != nil {
+		db.withdrawals = withdrawals
+	}
+	db.locked = false
+	return err
+}
+
+func (db *fakeBalanceDB) Users() UserRepository {
+	return db
+}
+
+func (db *fakeBalanceDB) Withdrawals() WithdrawalRepository {
+	return db
+}
+
+func (db *fakeBalanceDB) Lock(uint) error {
+	db.locked = true
+	return nil
+}
+
+func (db *fakeBalanceDB) Stats(uint) (*models.Balance, error) {
+	balance := &models.Balance{Current: db.accrued}
+	for _, withdrawal := range db.withdrawals {
+		switch withdrawal.Status {
+		case models.WithdrawalStatusConfirmed:
+			balance.Withdrawn += withdrawal.Sum
+		case models.WithdrawalStatusPending:
+			balance.Pending += withdrawal.Sum
+		}
+	}
+	balance.Current -= balance.Withdrawn + balance.Pending
+	return balance, nil
+}
+
+func (db *fakeBalanceDB) Create(withdrawal *models.Withdrawal) error {
+	if !db.locked {
+		return errors.New("withdrawal is created without user lock")
+	}
+	for _, existing := range db.withdrawals {
+		if existing.OrderID == withdrawal.OrderID {
+			return &models.ConflictError{Entity: "withdrawal", Reason: "order is already paid with points"}
+		}
+	}
+	db.withdrawals = append(db.withdrawals, *withdrawal)
+	return nil
+}
+
+func (db *fakeBalanceDB) CalculateUserStats(_ context.Context, userID uint) (*models.Balance, error) {
+	return db.Stats(userID)
+}
+
+func (db *fakeBalanceDB) GetWithdrawalsByUserID(context.Context, uint, models.Page) (*[]models.Withdrawal, error) {
+	return &db.withdrawals, nil
+}
+
+func (db *fakeBalanceDB) TransitionWithdrawal(
+	context.Context,
+	string,
+	uint,
+	[]models.WithdrawalStatus,
+	models.WithdrawalStatus,
+) (*models.Withdrawal, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestBalanceServiceWithdraw(t *testing.T) {
+	spent := models.Withdrawal{OrderID: "12345678903", UserID: testUserID, Sum: 500, Status: models.WithdrawalStatusConfirmed}
+	tests := []struct {
+		name        string
+		accrued     int64
+		withdrawals []models.Withdrawal
+		input       WithdrawInput
+		want        models.Balance
+		wantErr     error
+	}{
+		{
+			name:    "confirmed",
+			accrued: 1000,
+			input:   WithdrawInput{Order: "2377225624", Sum: 751},
+			want:    models.Balance{Current: 249, Withdrawn: 751},
+		},
+		{
+			name:    "held",
+			accrued: 1000,
+			input:   WithdrawInput{Order: "2377225624", Sum: 751, Hold: true},
+			want:    models.Balance{Current: 249, Pending: 751},
+		},
+		{
+			name:        "whole balance",
+			accrued:     1000,
+			withdrawals: []models.Withdrawal{spent},
+			input:       WithdrawInput{Order: "2377225624", Sum: 500},
+			want:        models.Balance{Withdrawn: 1000},
+		},
+		{
+			name:        "insufficient funds",
+			accrued:     1000,
+			withdrawals: []models.Withdrawal{spent},
+			input:       WithdrawInput{Order: "2377225624", Sum: 751},
+			wantErr:     models.ErrInsufficientFunds,
+		},
+		{
+			name:        "order already paid",
+			accrued:     1000,
+			withdrawals: []models.Withdrawal{spent},
+			input:       WithdrawInput{Order: spent.OrderID, Sum: 100},
+			wantErr:     models.ErrConflict,
+		},
+		{
+			name:    "wrong checksum",
+			accrued: 1000,
+			input:   WithdrawInput{Order: "2377225625", Sum: 100},
+			wantErr: models.ErrInvalidNumber,
+		},
+		{
+			name:    "zero sum",
+			accrued: 1000,
+			input:   WithdrawInput{Order: "2377225624"},
+			wantErr: ErrInvalidSum,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditor := &fakeAuditor{}
+			db := &fakeBalanceDB{accrued: tt.accrued, withdrawals: tt.withdrawals}
+			s := NewBalanceService(auditor, luhnValidator{}, nil, db)
+
+			tt.input.UserID = testUserID
+			after, err := s.Withdraw(context.Background(), tt.input, Client{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if len(db.withdrawals) != len(tt.withdrawals) {
+					t.Errorf("got %d withdrawals after error, want %d", len(db.withdrawals), len(tt.withdrawals))
+				}
+				if len(auditor.events) != 0 {
+					t.Errorf("got %d audit events after error, want none", len(auditor.events))
+				}
+				return
+			}
+			if *after != tt.want {
+				t.Errorf("got balance %+v, want %+v", *after, tt.want)
+			}
+			if len(auditor.events) != 1 || auditor.events[0].Action != models.AuditActionWithdraw {
+				t.Fatalf("got audit events %+v, want one withdrawal", auditor.events)
+			}
+			wantAfter := fmt.Sprintf(`{"balance":%d,"withdraw":%d,"pending":%d,"expired":0}`, tt.want.Current, tt.want.Withdrawn, tt.want.Pending)
+			if auditor.events[0].After != wantAfter {
+				t.Errorf("got audit balance %s, want %s", auditor.events[0].After, wantAfter)
+			}
+		})
+	}
+}
+
+func TestBalanceServiceWithdrawReportsShortage(t *testing.T) {
+	db := &fakeBalanceDB{accrued: 500}
+	s := NewBalanceService(&fakeAuditor{}, luhnValidator{}, nil, db)
+
+	_, err := s.Withdraw(context.Background(), WithdrawInput{UserID: testUserID, Order: "2377225624", Sum: 751}, Client{})
+	var insufficient *models.InsufficientFundsError
+	if !errors.As(err, &insufficient) {
+		t.Fatalf("got error %v, want insufficient funds", err)
+	}
+	if insufficient.Balance != 500 || insufficient.Required != 751 {
+		t.Errorf("got balance %d and required %d, want 500 and 751", insufficient.Balance, insufficient.Required)
+	}
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+var (
+	ErrLoginRequired      = errors.New("login required")
+	ErrInvalidCredentials = errors.New("invalid login or password")
+	ErrInvalidResetToken  = errors.New("reset token is invalid or expired")
+	ErrInvalidOrderFormat = errors.New("order number must consist of digits")
+	ErrInvalidSum         = errors.New("sum must be positive")
+	ErrBatchEmpty         = errors.New("no order numbers")
+	ErrBatchTooLarge      = errors.New("too many order numbers")
+	ErrInvalidPage        = errors.New("page limit or offset is out of range")
+	ErrWebhookURL         = errors.New("webhook URL is not allowed")
+)
+
+// LockedError rejects login until RetryAfter passes
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("too many failed logins, retry in %.0fs", math.Ceil(e.RetryAfter.Seconds()))
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+
+	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/utils"
+)
+
+const MaxBatchSize = 1000
+
+// OrderService takes orders of users for accrual
+type OrderService struct {
+	validator OrderValidator
+	db        OrderDB
+}
+
+type OrderValidator interface {
+	Valid(number string) bool
+}
+
+type OrderDB interface {
+	ClaimOrder(ctx context.Context, order *models.Order) (*models.OrderClaim, error)
+	ClaimOrders(ctx context.Context, orders []models.Order) ([]models.OrderClaim, error)
+	GetOrdersByUserID(ctx context.Context, userID uint, page models.Page) (*[]models.Order, error)
+}
+
+type UploadResult string
+
+const (
+	UploadAccepted     UploadResult = "accepted"
+	UploadDuplicateOwn UploadResult = "duplicate-own"
+	UploadConflict     UploadResult = "conflict"
+	UploadInvalid      UploadResult = "invalid"
+)
+
+// BatchResult is outcome of one number of batch, Number is normalized if it is valid
+type BatchResult struct {
+	Number string
+	Result UploadResult
+}
+
+func NewOrderService(validator OrderValidator, db OrderDB) *OrderService {
+	return &OrderService{
+		validator: validator,
+		db:        db,
+	}
+}
+
+// Upload claims order for user. Order uploaded by another user is a conflict,
+// repeated upload by the same user is not created
+func (s *OrderService) Upload(ctx context.Context, userID uint, number string) (created bool, err error) {
+	orderNumber, err := utils.NormalizeOrderNumber(number)
+	if err != nil {
+		return false, ErrInvalidOrderFormat
+	}
+	if !s.validator.Valid(orderNumber) {
+		return false, &models.InvalidNumberError{Number: orderNumber}
+	}
+
+	claim, err := s.db.ClaimOrder(ctx, &models.Order{
+		ID:     orderNumber,
+		UserID: userID,
+		Status: models.OrderStatusNew,
+	})
+	if err != nil {
+		return false, err
+	}
+	if !claim.Created && claim.OwnerID != userID {
+		return false, &models.ConflictError{Entity: "order", Reason: "order was uploaded by another user"}
+	}
+	return claim.Created, nil
+}
+
+// UploadBatch claims valid numbers at once, results keep order of numbers
+func (s *OrderService) UploadBatch(ctx context.Context, userID uint, numbers []string) ([]BatchResult, error) {
+	if len(numbers) == 0 {
+		return nil, ErrBatchEmpty
+	}
+	if len(numbers) > MaxBatchSize {
+		return nil, ErrBatchTooLarge
+	}
+
+	results := make([]BatchResult, len(numbers))
+	orders := make([]models.Order, 0, len(numbers))
+	claimed := make([]int, 0, len(numbers)) // indexes in results of orders
+	for i, number := range numbers {
+		results[i].Number = number
+
+		orderNumber, err := utils.NormalizeOrderNumber(number)
+		if err != nil || !s.validator.Valid(orderNumber) {
+			results[i].Result = UploadInvalid
+			continue
+		}
+		results[i].Number = orderNumber
+		orders = append(orders, models.Order{
+			ID:     orderNumber,
+			UserID: userID,
+			Status: models.OrderStatusNew,
+		})
+		claimed = append(claimed, i)
+	}
+
+	if len(orders) == 0 {
+		return results, nil
+	}
+	claims, err := s.db.ClaimOrders(ctx, orders)
+	if err != nil {
+		return nil, err
+	}
+	for i, claim := range claims {
+		switch {
+		case claim.Created:
+			results[claimed[i]].Result = UploadAccepted
+		case claim.OwnerID == userID:
+			results[claimed[i]].Result = UploadDuplicateOwn
+		default:
+			results[claimed[i]].Result = UploadConflict
+		}
+	}
+	return results, nil
+}
+
+// List returns page of orders of user and whether there are more after it
+func (s *OrderService) List(ctx context.Context, userID uint, page models.Page) (orders []models.Order, hasMore bool, err error) {
+	fetch, err := fetchPage(page)
+	if err != nil {
+		return nil, false, err
+	}
+	found, err := s.db.GetOrdersByUserID(ctx, userID, fetch)
+	if err != nil {
+		return nil, false, err
+	}
+	if orders = *found; page.Limit > 0 && len(orders) > page.Limit {
+		return orders[:page.Limit], true, nil
+	}
+	return orders, false, nil
+}
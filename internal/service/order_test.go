@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ksusonic/gophermart/internal/models"
+)
+
+const testUserID = 1
+
+// fakeOrderDB claims orders as database does, orders are kept in upload order
+type fakeOrderDB struct {
+	orders []models.Order
+	claims int
+}
+
+func newFakeOrderDB(owners map[string]uint) *fakeOrderDB {
+	db := &fakeOrderDB{}
+	for number, owner := range owners {
+		db.orders = append(db.orders, models.Order{ID: number, UserID: owner, Status: models.OrderStatusNew})
+	}
+	return db
+}
+
+func (db *fakeOrderDB) ClaimOrder(_ context.Context, order *models.Order) (*models.OrderClaim, error) {
+	db.claims++
+	for _, existing := range db.orders {
+		if existing.ID == order.ID {
+			return &models.OrderClaim{OwnerID: existing.UserID}, nil
+		}
+	}
+	db.orders = append(db.orders, *order)
+	return &models.OrderClaim{OwnerID: order.UserID, Created: true}, nil
+}
+
+func (db *fakeOrderDB) ClaimOrders(ctx context.Context, orders []models.Order) ([]models.OrderClaim, error) {
+	claims := make([]models.OrderClaim, len(orders))
+	for i := range orders {
+		claim, err := db.ClaimOrder(ctx, &orders[i])
+		if err != nil {
+			return nil, err
+		}
+		claims[i] = *claim
+	}
+	return claims, nil
+}
+
+func (db *fakeOrderDB) GetOrdersByUserID(_ context.Context, userID uint, page models.Page) (*[]models.Order, error) {
+	var orders []models.Order
+	for _, order := range db.orders {
+		if order.UserID == userID {
+			orders = append(orders, order)
+		}
+	}
+	if page.Offset >= len(orders) {
+		return &[]models.Order{}, nil
+	}
+	orders = orders[page.Offset:]
+	if page.Limit > 0 && len(orders) > page.Limit {
+		orders = orders[:page.Limit]
+	}
+	return &orders, nil
+}
+
+func TestOrderServiceUpload(t *testing.T) {
+	tests := []struct {
+		name        string
+		owners      map[string]uint
+		number      string
+		wantCreated bool
+		wantErr     error
+	}{
+		{name: "new order", number: "12345678903", wantCreated: true},
+		{name: "number with spaces and dashes", number: "1234-5678 903", wantCreated: true},
+		{name: "duplicate of own order", owners: map[string]uint{"12345678903": testUserID}, number: "12345678903"},
+		{name: "order of another user", owners: map[string]uint{"12345678903": 2}, number: "12345678903", wantErr: models.ErrConflict},
+		{name: "not digits", number: "12a45", wantErr: ErrInvalidOrderFormat},
+		{name: "wrong checksum", number: "12345678904", wantErr: models.ErrInvalidNumber},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewOrderService(luhnValidator{}, newFakeOrderDB(tt.owners))
+
+			created, err := s.Upload(context.Background(), testUserID, tt.number)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if created != tt.wantCreated {
+				t.Errorf("got created %t, want %t", created, tt.wantCreated)
+			}
+		})
+	}
+}
+
+func TestOrderServiceUploadBatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		owners     map[string]uint
+		numbers    []string
+		want       []BatchResult
+		wantClaims int
+		wantErr    error
+	}{
+		{
+			name:   "results keep order of numbers",
+			owners: map[string]uint{"79927398713": 2, "4561261212345467": testUserID},
+			numbers: []string{
+				"12345678903",
+				"abc",
+				"79927398713",
+				"4561 2612 1234 5467",
+				"12345678904",
+				"2377225624",
+			},
+			want: []BatchResult{
+				{Number: "12345678903", Result: UploadAccepted},
+				{Number: "abc", Result: UploadInvalid},
+				{Number: "79927398713", Result: UploadConflict},
+				{Number: "4561261212345467", Result: UploadDuplicateOwn},
+				{Number: "12345678904", Result: UploadInvalid},
+				{Number: "2377225624", Result: UploadAccepted},
+			},
+			wantClaims: 4,
+		},
+		{
+			name:    "number repeated in batch",
+			numbers: []string{"12345678903", "1234-5678-903"},
+			want: []BatchResult{
+				{Number: "12345678903", Result: UploadAccepted},
+				{Number: "12345678903", Result: UploadDuplicateOwn},
+			},
+			wantClaims: 2,
+		},
+		{
+			name:    "only invalid numbers are not claimed",
+			numbers: []string{"abc", "12345678904"},
+			want: []BatchResult{
+				{Number: "abc", Result: UploadInvalid},
+				{Number: "12345678904", Result: UploadInvalid},
+			},
+		},
+		{name: "empty", wantErr: ErrBatchEmpty},
+		{name: "too large", numbers: make([]string, MaxBatchSize+1), wantErr: ErrBatchTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFakeOrderDB(tt.owners)
+			s := NewOrderService(luhnValidator{}, db)
+
+			results, err := s.UploadBatch(context.Background(), testUserID, tt.numbers)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(results, tt.want) {
+				t.Errorf("got results %v, want %v", results, tt.want)
+			}
+			if db.claims != tt.wantClaims {
+				t.Errorf("got %d claims, want %d", db.claims, tt.wantClaims)
+			}
+		})
+	}
+}
+
+func TestOrderServiceList(t *testing.T) {
+	owners := map[string]uint{"12345678903": testUserID, "79927398713": testUserID, "2377225624": testUserID, "4561261212345467": 2}
+	tests := []struct {
+		name        string
+		page        models.Page
+		wantLen     int
+		wantHasMore bool
+		wantErr     error
+	}{
+		{name: "whole list", wantLen: 3},
+		{name: "first page", page: models.Page{Limit: 2}, wantLen: 2, wantHasMore: true},
+		{name: "last page", page: models.Page{Limit: 2, Offset: 2}, wantLen: 1},
+		{name: "page of exact size", page: models.Page{Limit: 3}, wantLen: 3},
+		{name: "negative limit", page: models.Page{Limit: -1}, wantErr: ErrInvalidPage},
+		{name: "limit over max", page: models.Page{Limit: models.MaxPageLimit + 1}, wantErr: ErrInvalidPage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewOrderService(luhnValidator{}, newFakeOrderDB(owners))
+
+			orders, hasMore, err := s.List(context.Background(), testUserID, tt.page)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if len(orders) != tt.wantLen || hasMore != tt.wantHasMore {
+				t.Errorf("got %d orders and more %t, want %d and %t", len(orders), hasMore, tt.wantLen, tt.wantHasMore)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+
+	"github.com/ksusonic/gophermart/internal/models"
+)
+
+// Client is caller of operation, it is recorded in audit
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+type Auditor interface {
+	Record(ctx context.Context, event *models.AuditEvent)
+}
+
+// fetchPage checks page and returns it with one extra item, so it can be told if there are more
+func fetchPage(page models.Page) (models.Page, error) {
+	if page.Limit < 0 || page.Limit > models.MaxPageLimit || page.Offset < 0 {
+		return page, ErrInvalidPage
+	}
+	if page.Limit > 0 {
+		page.Limit++
+	}
+	return page, nil
+}
+
+// record audits event made by client
+func record(auditor Auditor, client Client, event *models.AuditEvent) {
+	event.IP = client.IP
+	event.UserAgent = client.UserAgent
+	// recorded change is already committed, so it is audited even if client has gone
+	auditor.Record(context.Background(), event)
+}
@@ -0,0 +1,22 @@
+package service
+
+import (
+	"context"
+
+	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/utils"
+)
+
+type fakeAuditor struct {
+	events []models.AuditEvent
+}
+
+func (a *fakeAuditor) Record(_ context.Context, event *models.AuditEvent) {
+	a.events = append(a.events, *event)
+}
+
+type luhnValidator struct{}
+
+func (luhnValidator) Valid(number string) bool {
+	return utils.LuhnValid(number)
+}
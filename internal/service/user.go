@@ -0,0 +1,390 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/audit"
+	"github.com/ksusonic/gophermart/internal/ctxdata"
+	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/utils"
+
+	"go.uber.org/zap"
+)
+
+const TokenTTL = 120 * time.Minute
+
+// UserService registers and authenticates users and manages their passwords
+type UserService struct {
+	auth      TokenIssuer
+	audit     Auditor
+	guard     LoginGuard
+	passwords Passwords
+	notifier  Notifier
+	db        UserDB
+	logger    *zap.SugaredLogger
+
+	dummyHash     string
+	resetTokenTTL time.Duration
+	resets        sync.WaitGroup // password resets sent in background
+}
+
+type TokenIssuer interface {
+	CreateSignedJWT(claims models.Claims, expiresAt time.Time) (string, error)
+}
+
+// LoginGuard counts every attempt as failed until it succeeds, so concurrent guesses can't exceed the limit
+type LoginGuard interface {
+	Begin(ctx context.Context, login, ip string) (attempt LoginAttempt, retryAfter time.Duration, err error)
+	Reset(ctx context.Context, login string) error
+}
+
+// LoginAttempt is begun by LoginGuard, nil if login is locked
+type LoginAttempt interface {
+	// Lockout is started by the attempt and is in effect unless it succeeds, zero if none
+	Lockout() time.Duration
+	Succeed(ctx context.Context) error
+}
+
+type Passwords interface {
+	Validate(login, password string) error
+	Hash(password string) (string, error)
+	Compare(password, hash string) bool
+	NeedsRehash(hash string) bool
+}
+
+type Notifier interface {
+	PasswordReset(login, token string, expiresAt time.Time) error
+}
+
+type UserDB interface {
+	CreateUser(ctx context.Context, user *models.User) error
+	CreatePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error
+
+	UpdateUserPasswordHash(ctx context.Context, userID uint, hash string) error
+	ChangeUserPassword(ctx context.Context, userID uint, hash string) (*models.User, error)
+	ResetUserPassword(ctx context.Context, tokenHash string, hash string, now time.Time) (*models.User, error)
+
+	GetUserByLogin(ctx context.Context, login string) (*models.User, error)
+	GetUserByID(ctx context.Context, id uint) (*models.User, error)
+	GetPasswordResetToken(ctx context.Context, tokenHash string, now time.Time) (*models.PasswordResetToken, error)
+}
+
+type Credentials struct {
+	Login    string
+	Password string
+}
+
+// Session is token of user valid until ExpiresAt
+type Session struct {
+	User      *models.User
+	Token     string
+	ExpiresAt time.Time
+}
+
+func NewUserService(
+	auth TokenIssuer,
+	auditor Auditor,
+	guard LoginGuard,
+	passwords Passwords,
+	notifier Notifier,
+	resetTokenTTL time.Duration,
+	db UserDB,
+	logger *zap.SugaredLogger,
+) *UserService {
+	dummyHash, err := passwords.Hash("dummy password")
+	if err != nil {
+		logger.Panicf("could not generate dummy hash: %v", err)
+	}
+
+	return &UserService{
+		auth:          auth,
+		audit:         auditor,
+		guard:         guard,
+		passwords:     passwords,
+		notifier:      notifier,
+		db:            db,
+		logger:        logger,
+		dummyHash:     dummyHash,
+		resetTokenTTL: resetTokenTTL,
+	}
+}
+
+// Register returns ErrLoginRequired or password.PolicyError if credentials are not acceptable
+func (s *UserService) Register(ctx context.Context, credentials Credentials, client Client) (*Session, error) {
+	if credentials.Login == "" {
+		return nil, ErrLoginRequired
+	}
+	if err := s.passwords.Validate(credentials.Login, credentials.Password); err != nil {
+		return nil, err
+	}
+
+	_, err := s.db.GetUserByLogin(ctx, credentials.Login)
+	if err == nil {
+		return nil, &models.ConflictError{Entity: "user", Reason: "user already exists"}
+	}
+	if !errors.Is(err, models.ErrNotFound) {
+		return nil, err
+	}
+
+	hashedPassword, err := s.passwords.Hash(credentials.Password)
+	if err != nil {
+		return nil, fmt.Errorf("could not hash password: %w", err)
+	}
+
+	user := models.User{
+		Login:        credentials.Login,
+		PasswordHash: hashedPassword,
+	}
+	if err := s.db.CreateUser(ctx, &user); err != nil {
+		return nil, err
+	}
+	record(s.audit, client, &models.AuditEvent{
+		ActorID: audit.Actor(user.ID),
+		Action:  models.AuditActionRegister,
+		Target:  user.Login,
+	})
+
+	return s.session(&user)
+}
+
+// Login returns ErrInvalidCredentials or LockedError if credentials are not accepted
+func (s *UserService) Login(ctx context.Context, credentials Credentials, client Client) (*Session, error) {
+	attempt, retryAfter, err := s.guard.Begin(ctx, credentials.Login, client.IP)
+	if err != nil {
+		return nil, err
+	}
+	if retryAfter > 0 {
+		record(s.audit, client, &models.AuditEvent{
+			Action: models.AuditActionLoginFailed,
+			Target: credentials.Login,
+			After:  audit.Value(map[string]string{"reason": "locked"}),
+		})
+		return nil, &LockedError{RetryAfter: retryAfter}
+	}
+
+	existingUser, err := s.db.GetUserByLogin(ctx, credentials.Login)
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		return nil, err
+	}
+
+	var reason string
+	var actorID uint
+	if existingUser == nil {
+		// keep response time the same as for existing users
+		s.passwords.Compare(credentials.Password, s.dummyHash)
+		reason = "user does not exist"
+	} else if !s.passwords.Compare(credentials.Password, existingUser.PasswordHash) {
+		reason = "invalid password"
+		actorID = existingUser.ID
+	}
+
+	if reason != "" {
+		record(s.audit, client, &models.AuditEvent{
+			ActorID: audit.Actor(actorID),
+			Action:  models.AuditActionLoginFailed,
+			Target:  credentials.Login,
+			After:   audit.Value(map[string]string{"reason": reason}),
+		})
+		if attempt.Lockout() > 0 {
+			return nil, &LockedError{RetryAfter: attempt.Lockout()}
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := attempt.Succeed(ctx); err != nil {
+		ctxdata.Logger(ctx, s.logger).Warnf("could not reset login attempts of %s: %v", credentials.Login, err)
+	}
+	s.rehashIfNeeded(ctx, existingUser, credentials.Password)
+
+	session, err := s.session(existingUser)
+	if err != nil {
+		return nil, err
+	}
+
+	record(s.audit, client, &models.AuditEvent{
+		ActorID: audit.Actor(existingUser.ID),
+		Action:  models.AuditActionLogin,
+		Target:  existingUser.Login,
+	})
+	return session, nil
+}
+
+// ChangePassword revokes other sessions of user and returns a fresh one.
+// Wrong current password counts as failed login, so a stolen session can't be used to guess it
+func (s *UserService) ChangePassword(ctx context.Context, userID uint, current, password string, client Client) (*Session, error) {
+	user, err := s.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	attempt, retryAfter, err := s.guard.Begin(ctx, user.Login, client.IP)
+	if err != nil {
+		return nil, err
+	}
+	if retryAfter > 0 {
+		return nil, &LockedError{RetryAfter: retryAfter}
+	}
+	if !s.passwords.Compare(current, user.PasswordHash) {
+		record(s.audit, client, &models.AuditEvent{
+			ActorID: audit.Actor(userID),
+			Action:  models.AuditActionLoginFailed,
+			Target:  user.Login,
+			After:   audit.Value(map[string]string{"reason": "invalid current password"}),
+		})
+		if attempt.Lockout() > 0 {
+			return nil, &LockedError{RetryAfter: attempt.Lockout()}
+		}
+		return nil, ErrInvalidCredentials
+	}
+	if err := attempt.Succeed(ctx); err != nil {
+		ctxdata.Logger(ctx, s.logger).Warnf("could not reset login attempts of %s: %v", user.Login, err)
+	}
+	if err := s.passwords.Validate(user.Login, password); err != nil {
+		return nil, err
+	}
+
+	hash, err := s.passwords.Hash(password)
+	if err != nil {
+		return nil, fmt.Errorf("could not hash password: %w", err)
+	}
+	user, err = s.db.ChangeUserPassword(ctx, userID, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	record(s.audit, client, &models.AuditEvent{
+		ActorID: audit.Actor(userID),
+		Action:  models.AuditActionPasswordChange,
+		Target:  user.Login,
+		After:   audit.Value(map[string]string{"reason": "change"}),
+	})
+	return s.session(user)
+}
+
+// RequestPasswordReset sends reset token to user in background. Neither response time nor outcome
+// depends on whether login exists, so logins can't be enumerated by it
+func (s *UserService) RequestPasswordReset(ctx context.Context, login string, client Client) {
+	// request context is canceled once response is sent, its request ID is kept for logs
+	ctx = ctxdata.WithRequestID(context.Background(), ctxdata.RequestID(ctx))
+
+	s.resets.Add(1)
+	go func() {
+		defer s.resets.Done()
+		if err := s.sendPasswordReset(ctx, login, client); err != nil {
+			ctxdata.Logger(ctx, s.logger).Errorf("could not send password reset of %s: %v", login, err)
+		}
+	}()
+}
+
+func (s *UserService) sendPasswordReset(ctx context.Context, login string, client Client) error {
+	user, err := s.db.GetUserByLogin(ctx, login)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, tokenHash, err := utils.GenerateToken()
+	if err != nil {
+		return fmt.Errorf("could not generate reset token: %w", err)
+	}
+	resetToken := models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(s.resetTokenTTL),
+	}
+	if err := s.db.CreatePasswordResetToken(ctx, &resetToken); err != nil {
+		return err
+	}
+
+	if err := s.notifier.PasswordReset(user.Login, token, resetToken.ExpiresAt); err != nil {
+		return fmt.Errorf("could not notify user %d: %w", user.ID, err)
+	}
+
+	record(s.audit, client, &models.AuditEvent{
+		ActorID: audit.Actor(user.ID),
+		Action:  models.AuditActionPasswordReset,
+		Target:  user.Login,
+	})
+	return nil
+}
+
+// ResetPassword sets password of user the token was sent to and consumes the token
+func (s *UserService) ResetPassword(ctx context.Context, token, password string, client Client) error {
+	tokenHash := utils.HashToken(token)
+	resetToken, err := s.db.GetPasswordResetToken(ctx, tokenHash, time.Now())
+	if errors.Is(err, models.ErrNotFound) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+
+	user, err := s.db.GetUserByID(ctx, resetToken.UserID)
+	if err != nil {
+		return err
+	}
+	// validate before consuming the token, so a weak password does not burn it
+	if err := s.passwords.Validate(user.Login, password); err != nil {
+		return err
+	}
+
+	hash, err := s.passwords.Hash(password)
+	if err != nil {
+		return fmt.Errorf("could not hash password: %w", err)
+	}
+	user, err = s.db.ResetUserPassword(ctx, tokenHash, hash, time.Now())
+	if errors.Is(err, models.ErrNotFound) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.guard.Reset(ctx, user.Login); err != nil {
+		ctxdata.Logger(ctx, s.logger).Warnf("could not reset login attempts of %s: %v", user.Login, err)
+	}
+	record(s.audit, client, &models.AuditEvent{
+		ActorID: audit.Actor(user.ID),
+		Action:  models.AuditActionPasswordChange,
+		Target:  user.Login,
+		After:   audit.Value(map[string]string{"reason": "reset"}),
+	})
+	return nil
+}
+
+// session issues token for current session version of user
+func (s *UserService) session(user *models.User) (*Session, error) {
+	expiresAt := time.Now().Add(TokenTTL)
+	token, err := s.auth.CreateSignedJWT(models.Claims{
+		UserID:         user.ID,
+		SessionVersion: user.SessionVersion,
+		Admin:          user.Admin,
+	}, expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate token: %w", err)
+	}
+	return &Session{User: user, Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// rehashIfNeeded upgrades password hash of user to current algorithm and cost
+func (s *UserService) rehashIfNeeded(ctx context.Context, user *models.User, password string) {
+	if !s.passwords.NeedsRehash(user.PasswordHash) {
+		return
+	}
+	hash, err := s.passwords.Hash(password)
+	if err != nil {
+		ctxdata.Logger(ctx, s.logger).Warnf("could not rehash password of user %d: %v", user.ID, err)
+		return
+	}
+	if err := s.db.UpdateUserPasswordHash(ctx, user.ID, hash); err != nil {
+		ctxdata.Logger(ctx, s.logger).Warnf("could not update password hash of user %d: %v", user.ID, err)
+		return
+	}
+	ctxdata.Logger(ctx, s.logger).Debugf("upgraded password hash of user %d", user.ID)
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/password"
+
+	"go.uber.org/zap"
+)
+
+const (
+	testLogin    = "user"
+	testPassword = "Secret123"
+	testLockout  = time.Minute
+)
+
+type fakeTokens struct{}
+
+func (fakeTokens) CreateSignedJWT(models.Claims, time.Time) (string, error) {
+	return "token", nil
+}
+
+// fakePasswords applies real policy to passwords stored as is
+type fakePasswords struct{}
+
+func (fakePasswords) Validate(login, pass string) error {
+	return password.Policy{MinLength: 8, MinClasses: 3}.Validate(login, pass)
+}
+
+func (fakePasswords) Hash(pass string) (string, error) {
+	return "hash:" + pass, nil
+}
+
+func (fakePasswords) Compare(pass, hash string) bool {
+	return hash == "hash:"+pass
+}
+
+func (fakePasswords) NeedsRehash(string) bool {
+	return false
+}
+
+// fakeGuard locks login after maxFailures attempts that did not succeed
+type fakeGuard struct {
+	maxFailures int
+	failures    map[string]int
+}
+
+func newFakeGuard(maxFailures int) *fakeGuard {
+	return &fakeGuard{
+		maxFailures: maxFailures,
+		failures:    map[string]int{},
+	}
+}
+
+func (g *fakeGuard) Begin(_ context.Context, login, _ string) (LoginAttempt, time.Duration, error) {
+	if g.failures[login] >= g.maxFailures {
+		return nil, testLockout, nil
+	}
+	g.failures[login]++
+	attempt := &fakeAttempt{guard: g, login: login}
+	if g.failures[login] == g.maxFailures {
+		attempt.lockout = testLockout
+	}
+	return attempt, 0, nil
+}
+
+func (g *fakeGuard) Reset(_ context.Context, login string) error {
+	delete(g.failures, login)
+	return nil
+}
+
+type fakeAttempt struct {
+	guard   *fakeGuard
+	login   string
+	lockout time.Duration
+}
+
+func (a *fakeAttempt) Lockout() time.Duration {
+	return a.lockout
+}
+
+func (a *fakeAttempt) Succeed(context.Context) error {
+	delete(a.guard.failures, a.login)
+	return nil
+}
+
+// fakeNotifier records logins notified, or fails with err
+type fakeNotifier struct {
+	notified []string
+	err      error
+}
+
+func (n *fakeNotifier) PasswordReset(login, _ string, _ time.Time) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.notified = append(n.notified, login)
+	return nil
+}
+
+type fakeUserDB struct {
+	users       []models.User
+	resetTokens []models.PasswordResetToken
+}
+
+func (db *fakeUserDB) CreateUser(_ context.Context, user *models.User) error {
+	if _, err := db.GetUserByLogin(context.Background(), user.Login); err == nil {
+		return &models.ConflictError{Entity: "user", Reason: "user already exists"}
+	}
+	user.ID = uint(len(db.users) + 1)
+	db.users = append(db.users, *user)
+	return nil
+}
+
+func (db *fakeUserDB) GetUserByLogin(_ context.Context, login string) (*models.User, error) {
+	for i := range db.users {
+		if db.users[i].Login == login {
+			user := db.users[i]
+			return &user, nil
+		}
+	}
+	return nil, &models.NotFoundError{Entity: "user"}
+}
+
+func (db *fakeUserDB) GetUserByID(_ context.Context, id uint) (*models.User, error) {
+	for i := range db.users {
+		if db.users[i].ID == id {
+			user := db.users[i]
+			return &user, nil
+		}
+	}
+	return nil, &models.NotFoundError{Entity: "user"}
+}
+
+func (db *fakeUserDB) CreatePasswordResetToken(_ context.Context, token *models.PasswordResetToken) error {
+	db.resetTokens = append(db.resetTokens, *token)
+	return nil
+}
+
+func (db *fakeUserDB) UpdateUserPasswordHash(context.Context, uint, string) error {
+	return errors.New("not implemented")
+}
+
+func (db *fakeUserDB) ChangeUserPassword(context.Context, uint, string) (*models.User, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (db *fakeUserDB) ResetUserPassword(context.Context, string, string, time.Time) (*models.User, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (db *fakeUserDB) GetPasswordResetToken(context.Context, string, time.Time) (*models.PasswordResetToken, error) {
+	return nil, errors.New("not implemented")
+}
+
+// testUser is user 1 with testPassword
+func testUser(login string) models.User {
+	user := models.User{Login: login, PasswordHash: "hash:" + testPassword}
+	user.ID = testUserID
+	return user
+}
+
+func newTestUserService(t *testing.T, guard LoginGuard, db UserDB) *UserService {
+	t.Helper()
+	return NewUserService(fakeTokens{}, &fakeAuditor{}, guard, fakePasswords{}, nil, time.Hour, db, zap.NewNop().Sugar())
+}
+
+func TestUserServiceRegister(t *testing.T) {
+	tests := []struct {
+		name        string
+		credentials Credentials
+		existing    bool
+		wantErr     error
+	}{
+		{name: "new user", credentials: Credentials{Login: testLogin, Password: testPassword}},
+		{name: "existing user", credentials: Credentials{Login: testLogin, Password: testPassword}, existing: true, wantErr: models.ErrConflict},
+		{name: "empty login", credentials: Credentials{Password: testPassword}, wantErr: ErrLoginRequired},
+		{name: "short password", credentials: Credentials{Login: testLogin, Password: "Se1"}, wantErr: password.ErrTooShort},
+		{name: "simple password", credentials: Credentials{Login: testLogin, Password: "secretsecret"}, wantErr: password.ErrTooSimple},
+		{name: "weak password of existing user", credentials: Credentials{Login: testLogin, Password: "Se1"}, existing: true, wantErr: password.ErrTooShort},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeUserDB{}
+			if tt.existing {
+				db.users = []models.User{testUser(tt.credentials.Login)}
+			}
+			s := newTestUserService(t, newFakeGuard(3), db)
+
+			session, err := s.Register(context.Background(), tt.credentials, Client{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (session.User.Login != tt.credentials.Login || session.Token == "") {
+				t.Errorf("got session %+v of %+v, want token of %s", session, session.User, tt.credentials.Login)
+			}
+		})
+	}
+}
+
+func TestUserServiceLogin(t *testing.T) {
+	type step struct {
+		login      string
+		password   string
+		wantErr    error
+		wantLocked bool
+	}
+	var (
+		valid   = step{login: testLogin, password: testPassword}
+		invalid = step{login: testLogin, password: "Wrong123", wantErr: ErrInvalidCredentials}
+		locked  = step{login: testLogin, password: "Wrong123", wantLocked: true}
+	)
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{name: "valid credentials", steps: []step{valid}},
+		{name: "invalid password", steps: []step{invalid}},
+		{name: "unknown login", steps: []step{{login: "nobody", password: testPassword, wantErr: ErrInvalidCredentials}}},
+		{name: "failure reaching limit locks login", steps: []step{invalid, invalid, locked}},
+		{name: "locked login rejects valid password", steps: []step{invalid, invalid, locked, {login: testLogin, password: testPassword, wantLocked: true}}},
+		{name: "lock of login keeps other logins", steps: []step{invalid, invalid, locked, {login: "nobody", password: testPassword, wantErr: ErrInvalidCredentials}}},
+		{name: "success forgets failures", steps: []step{invalid, invalid, valid, invalid, invalid, valid}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeUserDB{users: []models.User{testUser(testLogin)}}
+			s := newTestUserService(t, newFakeGuard(3), db)
+
+			for i, step := range tt.steps {
+				session, err := s.Login(context.Background(), Credentials{Login: step.login, Password: step.password}, Client{IP: "127.0.0.1"})
+
+				var lockedErr *LockedError
+				switch {
+				case step.wantLocked:
+					if !errors.As(err, &lockedErr) || lockedErr.RetryAfter != testLockout {
+						t.Fatalf("step %d: got error %v, want lockout for %s", i, err, testLockout)
+					}
+				case !errors.Is(err, step.wantErr):
+					t.Fatalf("step %d: got error %v, want %v", i, err, step.wantErr)
+				case err == nil && session.User.Login != step.login:
+					t.Fatalf("step %d: got session of %s, want %s", i, session.User.Login, step.login)
+				}
+			}
+		})
+	}
+}
+
+func TestUserServiceRequestPasswordReset(t *testing.T) {
+	tests := []struct {
+		name         string
+		login        string
+		notifierErr  error
+		wantTokens   int
+		wantNotified int
+		wantAudited  bool
+	}{
+		{name: "existing user", login: testLogin, wantTokens: 1, wantNotified: 1, wantAudited: true},
+		{name: "unknown login", login: "other"},
+		{name: "notifier fails", login: testLogin, notifierErr: errors.New("mail is down"), wantTokens: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeUserDB{users: []models.User{testUser(testLogin)}}
+			notifier := &fakeNotifier{err: tt.notifierErr}
+			auditor := &fakeAuditor{}
+			s := NewUserService(fakeTokens{}, auditor, newFakeGuard(3), fakePasswords{}, notifier, time.Hour, db, zap.NewNop().Sugar())
+
+			s.RequestPasswordReset(context.Background(), tt.login, Client{})
+			s.resets.Wait()
+
+			if len(db.resetTokens) != tt.wantTokens || len(notifier.notified) != tt.wantNotified {
+				t.Errorf("got %d tokens and %d notifications, want %d and %d",
+					len(db.resetTokens), len(notifier.notified), tt.wantTokens, tt.wantNotified)
+			}
+			if audited := len(auditor.events) > 0; audited != tt.wantAudited {
+				t.Errorf("reset audited %v, want %v", audited, tt.wantAudited)
+			}
+		})
+	}
+}
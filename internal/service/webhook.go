@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ksusonic/gophermart/internal/audit"
+	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/utils"
+)
+
+// WebhookService manages webhook subscriptions of partners on behalf of admins
+type WebhookService struct {
+	audit        Auditor
+	destinations WebhookDestinations
+	db           WebhookDB
+}
+
+// WebhookDestinations tells whether webhooks may be sent to URL
+type WebhookDestinations interface {
+	Check(rawURL string) error
+}
+
+type WebhookDB interface {
+	CreateWebhookSubscription(ctx context.Context, subscription *models.WebhookSubscription) error
+	DeleteWebhookSubscription(ctx context.Context, id uint) error
+}
+
+// SubscribeInput subscribes URL of Partner to Events on behalf of ActorID.
+// Empty Secret is generated
+type SubscribeInput struct {
+	ActorID uint
+	Partner string
+	URL     string
+	Secret  string
+	Events  []string
+}
+
+func NewWebhookService(auditor Auditor, destinations WebhookDestinations, db WebhookDB) *WebhookService {
+	return &WebhookService{
+		audit:        auditor,
+		destinations: destinations,
+		db:           db,
+	}
+}
+
+// Subscribe returns created subscription with its secret, the secret is not audited.
+// URL not allowed by destinations is ErrWebhookURL
+func (s *WebhookService) Subscribe(ctx context.Context, input SubscribeInput, client Client) (*models.WebhookSubscription, error) {
+	if err := s.destinations.Check(input.URL); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWebhookURL, err)
+	}
+	if input.Secret == "" {
+		secret, _, err := utils.GenerateToken()
+		if err != nil {
+			return nil, fmt.Errorf("could not generate webhook secret: %w", err)
+		}
+		input.Secret = secret
+	}
+
+	subscription := &models.WebhookSubscription{
+		Partner: input.Partner,
+		URL:     input.URL,
+		Secret:  input.Secret,
+		Events:  strings.Join(input.Events, ","),
+	}
+	if err := s.db.CreateWebhookSubscription(ctx, subscription); err != nil {
+		return nil, fmt.Errorf("could not create webhook subscription: %w", err)
+	}
+
+	record(s.audit, client, &models.AuditEvent{
+		ActorID: audit.Actor(input.ActorID),
+		Action:  models.AuditActionWebhookSubscribed,
+		Target:  strconv.FormatUint(uint64(subscription.ID), 10),
+		After: audit.Value(map[string]any{
+			"partner": subscription.Partner,
+			"url":     subscription.URL,
+			"events":  input.Events,
+		}),
+	})
+	return subscription, nil
+}
+
+// Unsubscribe returns not found error if there is no subscription with id
+func (s *WebhookService) Unsubscribe(ctx context.Context, actorID, id uint, client Client) error {
+	if err := s.db.DeleteWebhookSubscription(ctx, id); err != nil {
+		return err
+	}
+
+	record(s.audit, client, &models.AuditEvent{
+		ActorID: audit.Actor(actorID),
+		Action:  models.AuditActionWebhookUnsubscribed,
+		Target:  strconv.FormatUint(uint64(id), 10),
+	})
+	return nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ksusonic/gophermart/internal/models"
+)
+
+type fakeWebhookDB struct {
+	subscriptions map[uint]models.WebhookSubscription
+}
+
+func (db *fakeWebhookDB) CreateWebhookSubscription(_ context.Context, subscription *models.WebhookSubscription) error {
+	subscription.ID = uint(len(db.subscriptions) + 1)
+	db.subscriptions[subscription.ID] = *subscription
+	return nil
+}
+
+func (db *fakeWebhookDB) DeleteWebhookSubscription(_ context.Context, id uint) error {
+	if _, ok := db.subscriptions[id]; !ok {
+		return &models.NotFoundError{Entity: "webhook subscription"}
+	}
+	delete(db.subscriptions, id)
+	return nil
+}
+
+// httpsOnly allows https URLs, as webhook.Destinations does for public hosts
+type httpsOnly struct{}
+
+func (httpsOnly) Check(rawURL string) error {
+	if !strings.HasPrefix(rawURL, "https://") {
+		return errors.New("https required")
+	}
+	return nil
+}
+
+func TestWebhookServiceSubscribe(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+	}{
+		{name: "given secret", secret: "partner secret"},
+		{name: "generated secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditor := &fakeAuditor{}
+			db := &fakeWebhookDB{subscriptions: map[uint]models.WebhookSubscription{}}
+			s := NewWebhookService(auditor, httpsOnly{}, db)
+
+			subscription, err := s.Subscribe(context.Background(), SubscribeInput{
+				ActorID: testUserID,
+				Partner: "partner",
+				URL:     "https://partner.example/hook",
+				Secret:  tt.secret,
+				Events:  []string{"order.processed", "balance.changed"},
+			}, Client{IP: "127.0.0.1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if subscription.Secret == "" || tt.secret != "" && subscription.Secret != tt.secret {
+				t.Errorf("got secret %q, want %q or generated", subscription.Secret, tt.secret)
+			}
+			if subscription.Events != "order.processed,balance.changed" {
+				t.Errorf("got events %q", subscription.Events)
+			}
+
+			if len(auditor.events) != 1 {
+				t.Fatalf("got audit events %+v, want one subscription", auditor.events)
+			}
+			event := auditor.events[0]
+			if event.Action != models.AuditActionWebhookSubscribed || event.Target != "1" ||
+				event.ActorID.Int64 != testUserID || event.IP != "127.0.0.1" {
+				t.Errorf("got audit event %+v", event)
+			}
+			if strings.Contains(event.After, subscription.Secret) {
+				t.Errorf("secret is audited in %s", event.After)
+			}
+		})
+	}
+}
+
+func TestWebhookServiceSubscribeRejectsURL(t *testing.T) {
+	auditor := &fakeAuditor{}
+	db := &fakeWebhookDB{subscriptions: map[uint]models.WebhookSubscription{}}
+	s := NewWebhookService(auditor, httpsOnly{}, db)
+
+	_, err := s.Subscribe(context.Background(), SubscribeInput{
+		ActorID: testUserID,
+		Partner: "partner",
+		URL:     "http://partner.example/hook",
+	}, Client{})
+	if !errors.Is(err, ErrWebhookURL) {
+		t.Fatalf("got error %v, want %v", err, ErrWebhookURL)
+	}
+	if len(db.subscriptions) != 0 || len(auditor.events) != 0 {
+		t.Errorf("got subscriptions %+v and audit events %+v of rejected URL", db.subscriptions, auditor.events)
+	}
+}
+
+func TestWebhookServiceUnsubscribe(t *testing.T) {
+	auditor := &fakeAuditor{}
+	db := &fakeWebhookDB{subscriptions: map[uint]models.WebhookSubscription{1: {Partner: "partner"}}}
+	s := NewWebhookService(auditor, httpsOnly{}, db)
+
+	if err := s.Unsubscribe(context.Background(), testUserID, 2, Client{}); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("got error %v, want not found", err)
+	}
+	if len(auditor.events) != 0 {
+		t.Fatalf("got audit events %+v of missing subscription", auditor.events)
+	}
+
+	if err := s.Unsubscribe(context.Background(), testUserID, 1, Client{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(auditor.events) != 1 || auditor.events[0].Action != models.AuditActionWebhookUnsubscribed || auditor.events[0].Target != "1" {
+		t.Errorf("got audit events %+v, want one unsubscription", auditor.events)
+	}
+}
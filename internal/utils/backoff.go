@@ -0,0 +1,15 @@
+package utils
+
+import "time"
+
+// Backoff returns delay before retry after attempts failures: base doubled for every next attempt, capped at max
+func Backoff(base, max time.Duration, attempts int) time.Duration {
+	backoff := base
+	for i := 1; i < attempts && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		backoff = max
+	}
+	return backoff
+}
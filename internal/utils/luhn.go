@@ -1,25 +1,53 @@
 package utils
 
-// LuhnValid check if number is valid based on Luhn algorithm
-func LuhnValid(number int64) bool {
-	return (number%10+checksum(number/10))%10 == 0
-}
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
 
-func checksum(number int64) int64 {
-	var luhn int64
+var ErrOrderNumberFormat = errors.New("order number must consist of digits")
 
-	for i := 0; number > 0; i++ {
-		cur := number % 10
+// LuhnValid check if number of any length is valid based on Luhn algorithm
+func LuhnValid(number string) bool {
+	if number == "" {
+		return false
+	}
 
-		if i%2 == 0 { // even
+	var sum int
+	for i := len(number) - 1; i >= 0; i-- {
+		if number[i] < '0' || number[i] > '9' {
+			return false
+		}
+		cur := int(number[i] - '0')
+
+		if (len(number)-1-i)%2 == 1 { // every second digit from the right
 			cur = cur * 2
 			if cur > 9 {
 				cur = cur%10 + cur/10
 			}
 		}
+		sum += cur
+	}
+	return sum%10 == 0
+}
+
+// NormalizeOrderNumber removes whitespace and dashes, keeping leading zeros
+func NormalizeOrderNumber(raw string) (string, error) {
+	number := strings.Map(func(r rune) rune {
+		if r == '-' || unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, raw)
 
-		luhn += cur
-		number = number / 10
+	if number == "" {
+		return "", ErrOrderNumberFormat
+	}
+	for i := 0; i < len(number); i++ {
+		if number[i] < '0' || number[i] > '9' {
+			return "", ErrOrderNumberFormat
+		}
 	}
-	return luhn % 10
+	return number, nil
 }
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLuhnValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		number string
+		want   bool
+	}{
+		{name: "odd length", number: "79927398713", want: true},
+		{name: "even length", number: "4539578763621486", want: true},
+		{name: "single zero", number: "0", want: true},
+		{name: "two digits", number: "18", want: true},
+		{name: "leading zero", number: "059", want: true},
+		{name: "leading zeros keep parity", number: "0079927398713", want: true},
+		{name: "longer than int64", number: "00000000000000000000000000000079927398713", want: true},
+		{name: "odd length wrong check digit", number: "79927398710"},
+		{name: "even length wrong check digit", number: "4539578763621487"},
+		{name: "single digit", number: "1"},
+		{name: "two digits wrong", number: "19"},
+		{name: "empty", number: ""},
+		{name: "letter", number: "7992739871a"},
+		{name: "separators are not normalized", number: "7992-7398-713"},
+		{name: "space", number: "7992 7398713"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LuhnValid(tt.number); got != tt.want {
+				t.Errorf("LuhnValid(%q) = %v, want %v", tt.number, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeOrderNumber(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr error
+	}{
+		{name: "digits", raw: "79927398713", want: "79927398713"},
+		{name: "dashes", raw: "7992-7398-713", want: "79927398713"},
+		{name: "spaces", raw: " 7992 7398 713 ", want: "79927398713"},
+		{name: "tabs and newlines", raw: "7992\t7398\n713", want: "79927398713"},
+		{name: "mixed separators", raw: "7992 - 7398-713", want: "79927398713"},
+		{name: "leading zeros", raw: "00-18", want: "0018"},
+		{name: "empty", raw: "", wantErr: ErrOrderNumberFormat},
+		{name: "only separators", raw: " - -", wantErr: ErrOrderNumberFormat},
+		{name: "letters", raw: "7992-abc", wantErr: ErrOrderNumberFormat},
+		{name: "sign", raw: "+79927398713", wantErr: ErrOrderNumberFormat},
+		{name: "non ascii digits", raw: "٧٩٩", wantErr: ErrOrderNumberFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeOrderNumber(tt.raw)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NormalizeOrderNumber(%q) error = %v, want %v", tt.raw, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeOrderNumber(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenDestination is returned for URLs webhooks must not be sent to
+var ErrForbiddenDestination = errors.New("webhook destination is not allowed")
+
+// internalNetworks are not public, but are not reported by methods of net.IP
+var internalNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"), // carrier-grade NAT, also metadata of some clouds
+	mustParseCIDR("192.0.0.0/24"),
+	mustParseCIDR("198.18.0.0/15"),
+}
+
+// Destinations keeps signed payloads away from internal services: webhooks are sent over https
+// to public addresses only. AllowInsecure lifts both rules, for local development
+type Destinations struct {
+	AllowInsecure bool
+}
+
+// Check rejects URLs that are not https or name localhost or a private address.
+// Names resolving to private addresses are refused by the dialer of Client
+func (d Destinations) Check(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrForbiddenDestination, err)
+	}
+	if u.Scheme != "https" && !(u.Scheme == "http" && d.AllowInsecure) {
+		return fmt.Errorf("%w: scheme %q, https required", ErrForbiddenDestination, u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return fmt.Errorf("%w: no host", ErrForbiddenDestination)
+	}
+	if d.AllowInsecure {
+		return nil
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", ErrForbiddenDestination, host)
+	}
+	if ip := net.ParseIP(host); ip != nil && !public(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenDestination, host)
+	}
+	return nil
+}
+
+// Client sends requests to allowed destinations only. It does not follow redirects,
+// which could lead to plain http, and ignores proxy settings, as a proxy connects anywhere
+func (d Destinations) Client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: d.control}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// control runs for every address the name resolved to, right before connecting
+func (d Destinations) control(_, address string, _ syscall.RawConn) error {
+	if d.AllowInsecure {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrForbiddenDestination, err)
+	}
+	if ip := net.ParseIP(host); ip == nil || !public(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenDestination, host)
+	}
+	return nil
+}
+
+func public(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range internalNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDestinationsCheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		url           string
+		allowInsecure bool
+		wantErr       bool
+	}{
+		{name: "https", url: "https://partner.example/hook"},
+		{name: "public address", url: "https://93.184.216.34/hook"},
+		{name: "plain http", url: "http://partner.example/hook", wantErr: true},
+		{name: "other scheme", url: "file:///etc/passwd", wantErr: true},
+		{name: "no host", url: "https:///hook", wantErr: true},
+		{name: "localhost", url: "https://localhost/hook", wantErr: true},
+		{name: "subdomain of localhost", url: "https://api.localhost/hook", wantErr: true},
+		{name: "loopback", url: "https://127.0.0.1:8080/hook", wantErr: true},
+		{name: "loopback v6", url: "https://[::1]/hook", wantErr: true},
+		{name: "private", url: "https://10.0.0.5/hook", wantErr: true},
+		{name: "private v6", url: "https://[fd00::1]/hook", wantErr: true},
+		{name: "mapped private", url: "https://[::ffff:192.168.1.1]/hook", wantErr: true},
+		{name: "metadata", url: "https://169.254.169.254/latest/meta-data", wantErr: true},
+		{name: "carrier-grade NAT", url: "https://100.100.100.200/hook", wantErr: true},
+		{name: "unspecified", url: "https://0.0.0.0/hook", wantErr: true},
+		{name: "insecure http", url: "http://partner.example/hook", allowInsecure: true},
+		{name: "insecure loopback", url: "http://127.0.0.1:9090/hook", allowInsecure: true},
+		{name: "insecure other scheme", url: "file:///etc/passwd", allowInsecure: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Destinations{AllowInsecure: tt.allowInsecure}.Check(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check(%q) = %v, want error %v", tt.url, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrForbiddenDestination) {
+				t.Errorf("got error %v, want %v", err, ErrForbiddenDestination)
+			}
+		})
+	}
+}
+
+// TestDestinationsClientRefusesPrivateAddresses checks names are refused by the address they resolve to
+func TestDestinationsClientRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	_, err := Destinations{}.Client(time.Second).Get(server.URL)
+	if !errors.Is(err, ErrForbiddenDestination) {
+		t.Errorf("got error %v connecting to loopback, want %v", err, ErrForbiddenDestination)
+	}
+
+	response, err := Destinations{AllowInsecure: true}.Client(time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+}
+
+func TestDestinationsClientDoesNotFollowRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/", http.StatusFound)
+	}))
+	defer server.Close()
+
+	response, err := Destinations{AllowInsecure: true}.Client(time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusFound {
+		t.Errorf("got status %d, want redirect not followed", response.StatusCode)
+	}
+}
@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/api"
+	"github.com/ksusonic/gophermart/internal/events"
+	"github.com/ksusonic/gophermart/internal/models"
+)
+
+type PublisherDB interface {
+	EnqueueWebhook(ctx context.Context, eventID uint, event models.WebhookEvent, payload []byte) error
+}
+
+var withdrawalEvents = map[models.WithdrawalStatus]models.WebhookEvent{
+	models.WithdrawalStatusPending:   models.WebhookEventWithdrawalCreated,
+	models.WithdrawalStatusConfirmed: models.WebhookEventWithdrawalConfirmed,
+	models.WithdrawalStatusCancelled: models.WebhookEventWithdrawalCancelled,
+	models.WithdrawalStatusReversed:  models.WebhookEventWithdrawalReversed,
+}
+
+// Publisher is an event subscriber enqueueing webhook deliveries of domain events partners are interested in
+type Publisher struct {
+	db PublisherDB
+}
+
+func NewPublisher(db PublisherDB) *Publisher {
+	return &Publisher{db: db}
+}
+
+func (p *Publisher) Handle(ctx context.Context, record *events.Record) error {
+	event, data, ok := translate(record.Event)
+	if !ok {
+		return nil
+	}
+	payload, err := json.Marshal(api.WebhookPayload{
+		Event:      string(event),
+		OccurredAt: record.OccurredAt.Format(time.RFC3339),
+		Data:       data,
+	})
+	if err != nil {
+		return fmt.Errorf("could not marshal webhook payload: %w", err)
+	}
+	return p.db.EnqueueWebhook(ctx, record.ID, event, payload)
+}
+
+func translate(event events.Event) (models.WebhookEvent, any, bool) {
+	switch e := event.(type) {
+	case *events.OrderUploaded:
+		return models.WebhookEventOrderUploaded, api.WebhookOrder{
+			Number: e.Number,
+			UserID: e.UserID,
+			Status: string(models.OrderStatusNew),
+		}, true
+	case *events.OrderAccrued:
+		return models.WebhookEventOrderProcessed, api.WebhookOrder{
+			Number:  e.Number,
+			UserID:  e.UserID,
+			Status:  string(models.OrderStatusProcessed),
+			Accrual: float64(e.Accrual) / 100,
+		}, true
+	case *events.OrderRejected:
+		return models.WebhookEventOrderInvalid, api.WebhookOrder{
+			Number: e.Number,
+			UserID: e.UserID,
+			Status: string(models.OrderStatusInvalid),
+		}, true
+	case *events.OrderAdjusted:
+		return models.WebhookEventOrderAdjusted, api.WebhookAdjustment{
+			Number: e.Number,
+			UserID: e.UserID,
+			Before: float64(e.Before) / 100,
+			After:  float64(e.After) / 100,
+			Delta:  float64(e.After-e.Before) / 100,
+		}, true
+	case *events.PointsWithdrawn:
+		return models.WebhookEventWithdrawalCreated, withdrawal(e.Order, e.UserID, e.Sum, e.Status), true
+	case *events.WithdrawalStatusChanged:
+		webhookEvent, ok := withdrawalEvents[e.Status]
+		return webhookEvent, withdrawal(e.Order, e.UserID, e.Sum, e.Status), ok
+	}
+	return "", nil, false
+}
+
+func withdrawal(order string, userID uint, sum int64, status models.WithdrawalStatus) api.WebhookWithdrawal {
+	return api.WebhookWithdrawal{
+		Order:  order,
+		UserID: userID,
+		Sum:    float64(sum) / 100,
+		Status: string(status),
+	}
+}
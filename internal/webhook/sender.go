@@ -0,0 +1,170 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/models"
+	"github.com/ksusonic/gophermart/internal/utils"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	batchSize   = 100
+	concurrency = 10
+	maxErrorLen = 512
+)
+
+type DB interface {
+	ClaimWebhookDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) (*[]models.WebhookDelivery, error)
+	GetWebhookSubscriptionByID(ctx context.Context, id uint) (*models.WebhookSubscription, error)
+	RecordWebhookAttempt(ctx context.Context, delivery *models.WebhookDelivery, attempt *models.WebhookAttempt) error
+}
+
+type Config struct {
+	Interval     time.Duration // how often outbox is polled
+	Timeout      time.Duration // of a single request
+	MaxAttempts  int           // delivery fails after
+	Backoff      time.Duration // first retry delay, doubled for every next one
+	MaxBackoff   time.Duration
+	Destinations Destinations // webhooks may be sent to
+}
+
+// Sender delivers webhooks from outbox table
+type Sender struct {
+	db     DB
+	cfg    Config
+	client *http.Client
+	logger *zap.SugaredLogger
+}
+
+func NewSender(db DB, cfg Config, logger *zap.SugaredLogger) *Sender {
+	return &Sender{
+		db:     db,
+		cfg:    cfg,
+		client: cfg.Destinations.Client(cfg.Timeout),
+		logger: logger,
+	}
+}
+
+func (s *Sender) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.sendDue(ctx); err != nil {
+				s.logger.Errorf("could not send webhooks: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Sender) sendDue(ctx context.Context) error {
+	// lease outlives any attempt, expired lease means sender crashed and delivery is retried
+	deliveries, err := s.db.ClaimWebhookDeliveries(ctx, time.Now(), 2*s.cfg.Timeout, batchSize)
+	if err != nil {
+		return fmt.Errorf("could not claim deliveries: %w", err)
+	}
+
+	eg := &errgroup.Group{}
+	eg.SetLimit(concurrency)
+	for i := range *deliveries {
+		delivery := &(*deliveries)[i]
+		eg.Go(func() error {
+			s.deliver(ctx, delivery)
+			return nil
+		})
+	}
+	return eg.Wait()
+}
+
+func (s *Sender) deliver(ctx context.Context, delivery *models.WebhookDelivery) {
+	subscription, err := s.db.GetWebhookSubscriptionByID(ctx, delivery.SubscriptionID)
+	if errors.Is(err, models.ErrNotFound) {
+		s.record(ctx, delivery, &models.WebhookAttempt{Error: "subscription deleted"}, false, true)
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("could not get subscription %d: %v", delivery.SubscriptionID, err)
+		return
+	}
+
+	started := time.Now()
+	statusCode, err := s.post(ctx, subscription, delivery)
+	attempt := &models.WebhookAttempt{
+		StatusCode: statusCode,
+		Duration:   time.Since(started),
+	}
+	if err != nil {
+		attempt.Error = truncate(err.Error(), maxErrorLen)
+	}
+	delivery.Attempts++
+	s.record(ctx, delivery, attempt, err == nil, delivery.Attempts >= s.cfg.MaxAttempts)
+}
+
+func (s *Sender) post(ctx context.Context, subscription *models.WebhookSubscription, delivery *models.WebhookDelivery) (int, error) {
+	// subscriptions may predate the rules
+	if err := s.cfg.Destinations.Check(subscription.URL); err != nil {
+		return 0, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderEvent, string(delivery.Event))
+	request.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(delivery.ID), 10))
+	request.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	request.Header.Set(HeaderSignature, Sign(subscription.Secret, now, delivery.Payload))
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorLen))
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("unexpected status %s: %s", response.Status, body)
+	}
+	return response.StatusCode, nil
+}
+
+func (s *Sender) record(ctx context.Context, delivery *models.WebhookDelivery, attempt *models.WebhookAttempt, delivered, final bool) {
+	now := time.Now()
+	attempt.DeliveryID = delivery.ID
+	delivery.LastError = attempt.Error
+	switch {
+	case delivered:
+		delivery.DeliveredAt = sql.NullTime{Time: now, Valid: true}
+	case final:
+		delivery.FailedAt = sql.NullTime{Time: now, Valid: true}
+		s.logger.Warnf("giving up delivery %d of %s: %s", delivery.ID, delivery.Event, attempt.Error)
+	default:
+		delivery.NextAttemptAt = now.Add(utils.Backoff(s.cfg.Backoff, s.cfg.MaxBackoff, delivery.Attempts))
+	}
+
+	if err := s.db.RecordWebhookAttempt(ctx, delivery, attempt); err != nil {
+		s.logger.Errorf("could not record attempt of delivery %d: %v", delivery.ID, err)
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,143 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ksusonic/gophermart/internal/models"
+
+	"go.uber.org/zap"
+)
+
+const (
+	testSecret    = "secret"
+	testTolerance = 5 * time.Minute
+)
+
+var testBody = []byte(`{"order":"79927398713"}`)
+
+func TestSign(t *testing.T) {
+	// HMAC-SHA256 of "1688212800.<body>" computed independently
+	want := "sha256=be7c50549c79fca350d512492bc6e130997e3c55d1bf7d1c85820e9dbd50bae0"
+	if got := Sign(testSecret, time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC), testBody); got != want {
+		t.Errorf("got signature %s, want %s", got, want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		want      bool
+	}{
+		{
+			name:      "valid",
+			secret:    testSecret,
+			timestamp: unix(now),
+			signature: Sign(testSecret, now, testBody),
+			body:      testBody,
+			want:      true,
+		},
+		{
+			name:      "within tolerance",
+			secret:    testSecret,
+			timestamp: unix(now.Add(-testTolerance + time.Minute)),
+			signature: Sign(testSecret, now.Add(-testTolerance+time.Minute), testBody),
+			body:      testBody,
+			want:      true,
+		},
+		{
+			name:      "tampered body",
+			secret:    testSecret,
+			timestamp: unix(now),
+			signature: Sign(testSecret, now, testBody),
+			body:      []byte(`{"order":"79927398714"}`),
+		},
+		{
+			name:      "wrong secret",
+			secret:    "other secret",
+			timestamp: unix(now),
+			signature: Sign(testSecret, now, testBody),
+			body:      testBody,
+		},
+		{
+			name:      "stale timestamp",
+			secret:    testSecret,
+			timestamp: unix(now.Add(-testTolerance - time.Minute)),
+			signature: Sign(testSecret, now.Add(-testTolerance-time.Minute), testBody),
+			body:      testBody,
+		},
+		{
+			name:      "timestamp from future",
+			secret:    testSecret,
+			timestamp: unix(now.Add(testTolerance + time.Minute)),
+			signature: Sign(testSecret, now.Add(testTolerance+time.Minute), testBody),
+			body:      testBody,
+		},
+		{
+			name:      "timestamp changed after signing",
+			secret:    testSecret,
+			timestamp: unix(now.Add(time.Second)),
+			signature: Sign(testSecret, now, testBody),
+			body:      testBody,
+		},
+		{
+			name:      "malformed timestamp",
+			secret:    testSecret,
+			timestamp: "now",
+			signature: Sign(testSecret, now, testBody),
+			body:      testBody,
+		},
+		{
+			name:      "missing signature",
+			secret:    testSecret,
+			timestamp: unix(now),
+			body:      testBody,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify(tt.secret, tt.timestamp, tt.signature, tt.body, testTolerance); got != tt.want {
+				t.Errorf("Verify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSenderSignsVerifiably checks deliveries posted by Sender pass Verify of receivers
+func TestSenderSignsVerifiably(t *testing.T) {
+	var verified bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verified = Verify(testSecret, r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderSignature), body, testTolerance)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// test server listens on loopback over plain http
+	sender := NewSender(nil, Config{Timeout: time.Second, Destinations: Destinations{AllowInsecure: true}}, zap.NewNop().Sugar())
+	_, err := sender.post(
+		context.Background(),
+		&models.WebhookSubscription{URL: server.URL, Secret: testSecret},
+		&models.WebhookDelivery{Event: models.WebhookEventOrderProcessed, Payload: testBody},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verified {
+		t.Error("delivery of sender is not verified")
+	}
+}
+
+func unix(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}